@@ -0,0 +1,76 @@
+package circularfile
+
+import (
+	"io"
+	"os"
+)
+
+// Reader returns the entries of a circular file in chronological order, from the head to the tail found when it was
+// opened, including the entries written after the header. Entries written after it was opened are not returned
+type Reader struct {
+	file   *os.File
+	size   int64
+	offset int64
+	seq    uint64
+	tail   int64
+}
+
+// NewReader opens the circular file for reading
+func NewReader(filename string) (*Reader, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	size, persisted, err := readHeader(f)
+	if err == nil {
+		var p position
+		p, err = recoverPosition(f, size, persisted)
+		if err == nil {
+			return &Reader{file: f, size: size, offset: p.head, seq: p.headSeq, tail: p.tail}, nil
+		}
+	}
+	f.Close()
+	return nil, err
+}
+
+// Next returns the next entry or io.EOF after the last one
+func (r *Reader) Next() ([]byte, error) {
+	if r.offset == r.tail {
+		return nil, io.EOF
+	}
+	remaining := (r.tail - r.offset + r.size) % r.size
+	entry, err := readEntry(r.file, r.size, r.offset, r.seq)
+	if err != nil {
+		return nil, err
+	}
+	entrySize := int64(entryHeaderSize + len(entry))
+	if entrySize > remaining {
+		return nil, ErrInvalidFile
+	}
+	r.offset = (r.offset + entrySize) % r.size
+	r.seq++
+	return entry, nil
+}
+
+// WriteTo writes the remaining entries to w, one after the other
+func (r *Reader) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	for {
+		entry, err := r.Next()
+		if err == io.EOF {
+			return written, nil
+		}
+		if err != nil {
+			return written, err
+		}
+		n, err := w.Write(entry)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+}
+
+func (r *Reader) Close() error {
+	return r.file.Close()
+}
@@ -0,0 +1,45 @@
+package circularfile
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReaderWriteTo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "teste.log")
+	w, err := Open(filename, Options{Size: 112})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	// the fourth entry overwrites the first one and wraps around the end of the ring
+	for _, entry := range []string{"first entry\n", "second entry\n", "third entry\n", "fourth entry\n"} {
+		if _, err := w.Write([]byte(entry)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, err := NewReader(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	// the entries written after the reader is opened are not returned
+	if _, err := w.Write([]byte("not read\n")); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := r.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "second entry\nthird entry\nfourth entry\n" {
+		t.Fatalf("Unexpected content '%s'", buf.String())
+	}
+}
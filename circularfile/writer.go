@@ -0,0 +1,455 @@
+// Package circularfile writes the log to a preallocated file of fixed size, used as a ring: when it is full, the
+// oldest entries are overwritten instead of rotating and removing files
+package circularfile
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Murilovisque/logs/v3/internal/fileutil"
+)
+
+const (
+	magic   = "GOLOGRNG"
+	version = 1
+	// HeaderSize is the size of the header before the ring: the magic, the version, the size of the ring and the
+	// position of the entries
+	HeaderSize = 56
+	// MinSize is the smallest size of the ring
+	MinSize = 64
+	// entryHeaderSize is the size of the length, the checksum and the sequence written before each entry
+	entryHeaderSize = 16
+	sizeOffset      = 16
+	positionOffset  = 24
+)
+
+var (
+	ErrInvalidSize           = errors.New("circular file size is less than the minimum")
+	ErrInvalidHeaderInterval = errors.New("header interval is less than zero")
+	ErrSizeMismatch          = errors.New("circular file was created with another size")
+	ErrInvalidFile           = errors.New("file is not a valid circular log file")
+	ErrEntryTooLarge         = errors.New("entry is larger than the circular file")
+	ErrClosed                = errors.New("circular file writer is closed")
+	zeros                    = make([]byte, 32*1024)
+)
+
+// Owner of the files and directories created, applied only when the process runs as root
+type Owner = fileutil.Owner
+
+// Options configures a Writer
+type Options struct {
+	// Size of the ring, the file takes HeaderSize more bytes. It must be at least MinSize
+	Size int64
+	// HeaderInterval is how often the position of the entries is written to the header, if entries were written
+	// since the last time. It is also written by Sync and Close, the entries written after it are recovered by
+	// scanning the ring when the file is opened. Zero means one second
+	HeaderInterval time.Duration
+	// FileMode of the file when created. Zero means 0644
+	FileMode os.FileMode
+	// DirMode of the directories created. Zero means 0755
+	DirMode os.FileMode
+	// Owner is applied to the file and directories created when the process runs as root. Nil keeps the owner
+	Owner *Owner
+}
+
+// position of the entries in the ring: the head is the offset of the oldest entry and the tail the offset of the
+// next one. Each entry has a sequence, one more than the previous entry
+type position struct {
+	head    int64
+	tail    int64
+	headSeq uint64
+	seq     uint64
+}
+
+func (p position) used(size int64) int64 {
+	return (p.tail - p.head + size) % size
+}
+
+// Writer writes each call of Write as an entry of the ring, overwriting the oldest entries when there is no space.
+// The header is not written on every entry, so the storage is not worn on the same block
+type Writer struct {
+	file        *os.File
+	size        int64
+	pos         position
+	unpersisted int64
+	mux         sync.Mutex
+	closed      bool
+	stop        chan struct{}
+	done        chan struct{}
+}
+
+// Open opens the circular file, creating and preallocating it when it does not exist. An existing file keeps its
+// entries and must have been created with the same size
+func Open(filename string, opts Options) (*Writer, error) {
+	if opts.Size < MinSize {
+		return nil, ErrInvalidSize
+	}
+	if opts.HeaderInterval < 0 {
+		return nil, ErrInvalidHeaderInterval
+	}
+	perms := fileutil.Permissions{FileMode: opts.FileMode, DirMode: opts.DirMode, Owner: opts.Owner}
+	f, err := perms.OpenFile(filename, os.O_CREATE|os.O_RDWR)
+	if err != nil {
+		return nil, err
+	}
+	w := &Writer{file: f, size: opts.Size, stop: make(chan struct{}), done: make(chan struct{})}
+	info, err := f.Stat()
+	if err == nil && info.Size() == 0 {
+		err = w.preallocate()
+	} else if err == nil {
+		err = w.load()
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	interval := opts.HeaderInterval
+	if interval == 0 {
+		interval = time.Second
+	}
+	go persistingHeader(w, time.NewTicker(interval))
+	return w, nil
+}
+
+// Write writes p as a single entry. The entries overwritten to make space are removed whole, never partially
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	if w.closed {
+		return 0, ErrClosed
+	}
+	entrySize := int64(entryHeaderSize + len(p))
+	// one byte is always left free, so the head equal to the tail means an empty ring
+	if entrySize >= w.size {
+		return 0, ErrEntryTooLarge
+	}
+	// the entries are recovered by scanning from the tail in the header, so it is written before they wrap around it
+	if w.unpersisted+entrySize >= w.size {
+		if err := w.writeHeader(); err != nil {
+			return 0, err
+		}
+	}
+	pos := w.pos
+	for w.size-pos.used(w.size) <= entrySize {
+		length, seq, err := readEntryHeader(w.file, w.size, pos.head)
+		if err != nil {
+			return 0, err
+		}
+		if seq != pos.headSeq {
+			return 0, ErrInvalidFile
+		}
+		pos.head = (pos.head + entryHeaderSize + length) % w.size
+		pos.headSeq++
+	}
+	entry := make([]byte, entrySize)
+	binary.BigEndian.PutUint32(entry, uint32(len(p)))
+	binary.BigEndian.PutUint64(entry[8:], pos.seq)
+	copy(entry[entryHeaderSize:], p)
+	binary.BigEndian.PutUint32(entry[4:], checksum(entry[:entryHeaderSize], p))
+	if err := w.writeRing(pos.tail, entry); err != nil {
+		return 0, err
+	}
+	pos.tail = (pos.tail + entrySize) % w.size
+	pos.seq++
+	w.pos = pos
+	w.unpersisted += entrySize
+	return len(p), nil
+}
+
+// Sync writes the header and commits the entries written to the storage
+func (w *Writer) Sync() error {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	if w.closed {
+		return ErrClosed
+	}
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	return w.file.Sync()
+}
+
+// Filename returns the name of the circular file
+func (w *Writer) Filename() string {
+	return w.file.Name()
+}
+
+// Close writes the header, then syncs and closes the file
+func (w *Writer) Close() error {
+	w.mux.Lock()
+	if w.closed {
+		w.mux.Unlock()
+		return ErrClosed
+	}
+	w.closed = true
+	err := w.writeHeader()
+	if syncErr := w.file.Sync(); err == nil {
+		err = syncErr
+	}
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	w.mux.Unlock()
+	close(w.stop)
+	<-w.done
+	return err
+}
+
+func persistingHeader(w *Writer, tick *time.Ticker) {
+	defer close(w.done)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			w.mux.Lock()
+			if !w.closed && w.unpersisted > 0 {
+				// a failure is returned by the next Sync or Close, which write the header again
+				w.writeHeader()
+			}
+			w.mux.Unlock()
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// writeRing writes p at the offset of the ring, wrapping around its end
+func (w *Writer) writeRing(offset int64, p []byte) error {
+	n := int64(len(p))
+	if offset+n > w.size {
+		n = w.size - offset
+	}
+	if _, err := w.file.WriteAt(p[:n], HeaderSize+offset); err != nil {
+		return err
+	}
+	if n < int64(len(p)) {
+		_, err := w.file.WriteAt(p[n:], HeaderSize)
+		return err
+	}
+	return nil
+}
+
+// writeHeader writes the position of the entries, if any was written since the last time
+func (w *Writer) writeHeader() error {
+	if w.unpersisted == 0 {
+		return nil
+	}
+	if _, err := w.file.WriteAt(encodePosition(w.pos), positionOffset); err != nil {
+		return err
+	}
+	w.unpersisted = 0
+	return nil
+}
+
+// preallocate writes the header and fills the ring with zeros, so the blocks are allocated once
+func (w *Writer) preallocate() error {
+	// the zeros of the ring are never a valid entry, as the sequence starts at one
+	w.pos = position{headSeq: 1, seq: 1}
+	header := make([]byte, HeaderSize)
+	copy(header, magic)
+	header[len(magic)] = version
+	binary.BigEndian.PutUint64(header[sizeOffset:], uint64(w.size))
+	copy(header[positionOffset:], encodePosition(w.pos))
+	if _, err := w.file.WriteAt(header, 0); err != nil {
+		return err
+	}
+	for offset := int64(0); offset < w.size; offset += int64(len(zeros)) {
+		chunk := zeros
+		if remaining := w.size - offset; remaining < int64(len(chunk)) {
+			chunk = chunk[:remaining]
+		}
+		if _, err := w.file.WriteAt(chunk, HeaderSize+offset); err != nil {
+			return err
+		}
+	}
+	return w.file.Sync()
+}
+
+// load reads the header and recovers the entries written after it, writing the header again when there are any
+func (w *Writer) load() error {
+	size, persisted, err := readHeader(w.file)
+	if err != nil {
+		return err
+	}
+	if size != w.size {
+		return ErrSizeMismatch
+	}
+	w.pos, err = recoverPosition(w.file, size, persisted)
+	if err != nil {
+		return err
+	}
+	if w.pos != persisted {
+		_, err = w.file.WriteAt(encodePosition(w.pos), positionOffset)
+	}
+	return err
+}
+
+func encodePosition(p position) []byte {
+	buf := make([]byte, HeaderSize-positionOffset)
+	binary.BigEndian.PutUint64(buf, uint64(p.head))
+	binary.BigEndian.PutUint64(buf[8:], uint64(p.tail))
+	binary.BigEndian.PutUint64(buf[16:], p.headSeq)
+	binary.BigEndian.PutUint64(buf[24:], p.seq)
+	return buf
+}
+
+func readHeader(r io.ReaderAt) (int64, position, error) {
+	header := make([]byte, HeaderSize)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		if err == io.EOF {
+			err = ErrInvalidFile
+		}
+		return 0, position{}, err
+	}
+	if string(header[:len(magic)]) != magic || header[len(magic)] != version {
+		return 0, position{}, ErrInvalidFile
+	}
+	size := int64(binary.BigEndian.Uint64(header[sizeOffset:]))
+	buf := header[positionOffset:]
+	p := position{
+		head:    int64(binary.BigEndian.Uint64(buf)),
+		tail:    int64(binary.BigEndian.Uint64(buf[8:])),
+		headSeq: binary.BigEndian.Uint64(buf[16:]),
+		seq:     binary.BigEndian.Uint64(buf[24:]),
+	}
+	if size < MinSize || p.head < 0 || p.head >= size || p.tail < 0 || p.tail >= size {
+		return 0, position{}, ErrInvalidFile
+	}
+	return size, p, nil
+}
+
+// recoverPosition returns the position after the entries written since the header, found by scanning the ring from
+// the tail of the header while the entries have the next sequence and a valid checksum. When they overwrote the
+// oldest entries, the head is the first entry after them starting a chain of entries up to the tail of the header
+func recoverPosition(r io.ReaderAt, size int64, persisted position) (position, error) {
+	p := persisted
+	var written int64
+	for {
+		entry, err := readEntry(r, size, p.tail, p.seq)
+		if err == ErrInvalidFile {
+			break
+		}
+		if err != nil {
+			return persisted, err
+		}
+		entrySize := int64(entryHeaderSize + len(entry))
+		if written+entrySize >= size {
+			break
+		}
+		p.tail = (p.tail + entrySize) % size
+		p.seq++
+		written += entrySize
+	}
+	if persisted.used(size)+written < size {
+		return p, nil
+	}
+	// the entries left are between the new tail and the tail of the header
+	region := make([]byte, size-written)
+	if err := readRing(r, size, p.tail, region); err != nil {
+		return persisted, err
+	}
+	rr := &regionReader{region: region, start: p.tail, size: size}
+	p.head, p.headSeq = persisted.tail, persisted.seq
+	for distance := int64(1); distance < int64(len(region)); distance++ {
+		head := (p.tail + distance) % size
+		if headSeq, ok := chainReaches(rr, size, head, persisted); ok {
+			p.head, p.headSeq = head, headSeq
+			break
+		}
+	}
+	return p, nil
+}
+
+// chainReaches reports whether the entries from the offset are one after the other up to the tail of the position,
+// returning the sequence of the first one
+func chainReaches(r io.ReaderAt, size, offset int64, p position) (uint64, bool) {
+	_, headSeq, err := readEntryHeader(r, size, offset)
+	if err != nil || headSeq < p.headSeq || headSeq >= p.seq {
+		return 0, false
+	}
+	seq := headSeq
+	for offset != p.tail {
+		entry, err := readEntry(r, size, offset, seq)
+		if err != nil {
+			return 0, false
+		}
+		offset = (offset + entryHeaderSize + int64(len(entry))) % size
+		seq++
+	}
+	return headSeq, seq == p.seq
+}
+
+// regionReader reads a region of the ring kept in memory, returning io.EOF outside of it
+type regionReader struct {
+	region []byte
+	start  int64
+	size   int64
+}
+
+func (rr *regionReader) ReadAt(p []byte, off int64) (int, error) {
+	distance := (off - HeaderSize - rr.start + rr.size) % rr.size
+	if distance+int64(len(p)) > int64(len(rr.region)) {
+		return 0, io.EOF
+	}
+	return copy(p, rr.region[distance:]), nil
+}
+
+func readEntryHeader(r io.ReaderAt, size, offset int64) (int64, uint64, error) {
+	buf := make([]byte, entryHeaderSize)
+	if err := readRing(r, size, offset, buf); err != nil {
+		return 0, 0, err
+	}
+	length := int64(binary.BigEndian.Uint32(buf))
+	if entryHeaderSize+length >= size {
+		return 0, 0, ErrInvalidFile
+	}
+	return length, binary.BigEndian.Uint64(buf[8:]), nil
+}
+
+// readEntry reads the entry at the offset, returning ErrInvalidFile when it has not the sequence or the checksum
+func readEntry(r io.ReaderAt, size, offset int64, seq uint64) ([]byte, error) {
+	header := make([]byte, entryHeaderSize)
+	if err := readRing(r, size, offset, header); err != nil {
+		return nil, err
+	}
+	length := int64(binary.BigEndian.Uint32(header))
+	if entryHeaderSize+length >= size || binary.BigEndian.Uint64(header[8:]) != seq {
+		return nil, ErrInvalidFile
+	}
+	entry := make([]byte, length)
+	if err := readRing(r, size, (offset+entryHeaderSize)%size, entry); err != nil {
+		return nil, err
+	}
+	if binary.BigEndian.Uint32(header[4:]) != checksum(header, entry) {
+		return nil, ErrInvalidFile
+	}
+	return entry, nil
+}
+
+// checksum of the length, the sequence and the data of an entry
+func checksum(header, data []byte) uint32 {
+	crc := crc32.ChecksumIEEE(header[:4])
+	crc = crc32.Update(crc, crc32.IEEETable, header[8:entryHeaderSize])
+	return crc32.Update(crc, crc32.IEEETable, data)
+}
+
+// readRing reads len(p) bytes at the offset of the ring, wrapping around its end
+func readRing(r io.ReaderAt, size, offset int64, p []byte) error {
+	n := int64(len(p))
+	if offset+n > size {
+		n = size - offset
+	}
+	if _, err := r.ReadAt(p[:n], HeaderSize+offset); err != nil {
+		return err
+	}
+	if n < int64(len(p)) {
+		_, err := r.ReadAt(p[n:], HeaderSize)
+		return err
+	}
+	return nil
+}
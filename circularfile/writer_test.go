@@ -0,0 +1,232 @@
+package circularfile
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOpenPreallocatesFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "teste.log")
+	w, err := Open(filename, Options{Size: 100000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != HeaderSize+100000 {
+		t.Fatalf("Expected %d bytes, but found %d", HeaderSize+100000, info.Size())
+	}
+	assertEntries(t, filename)
+}
+
+func TestWriteOverwritesOldestEntries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "teste.log")
+	w, err := Open(filename, Options{Size: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// each entry takes 16 + 11 bytes, so the ring keeps the last 3
+	var expected []string
+	for i := 0; i < 20; i++ {
+		entry := fmt.Sprintf("entry %04d\n", i)
+		if _, err := w.Write([]byte(entry)); err != nil {
+			t.Fatal(err)
+		}
+		expected = append(expected, entry)
+		if i >= 3 {
+			expected = expected[1:]
+		}
+		assertEntries(t, filename, expected...)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != ErrClosed {
+		t.Fatalf("Expected %v, but received %v", ErrClosed, err)
+	}
+	w, err = Open(filename, Options{Size: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if _, err := w.Write([]byte("reopened\n")); err != nil {
+		t.Fatal(err)
+	}
+	assertEntries(t, filename, append(expected[1:], "reopened\n")...)
+}
+
+func TestWriteDoesNotWriteTheHeaderOnEveryEntry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "teste.log")
+	w, err := Open(filename, Options{Size: 1000, HeaderInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	header := readFileHeader(t, filename)
+	for _, entry := range []string{"first\n", "second\n", "third\n"} {
+		if _, err := w.Write([]byte(entry)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !bytes.Equal(readFileHeader(t, filename), header) {
+		t.Fatal("Expected the header not written by the entries")
+	}
+	assertEntries(t, filename, "first\n", "second\n", "third\n")
+	if err := w.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(readFileHeader(t, filename), header) {
+		t.Fatal("Expected the header written by Sync")
+	}
+}
+
+func TestOpenRecoversEntriesWrittenAfterTheHeader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "teste.log")
+	// each entry takes 16 + 11 bytes, so the ring keeps the last 3 and the oldest ones are overwritten without the
+	// header being written, as it is written only when 4 entries were written after it
+	var expected []string
+	i := 0
+	for round := 0; round < 12; round++ {
+		w, err := Open(filename, Options{Size: 100, HeaderInterval: time.Hour})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for n := 0; n <= round%5; n++ {
+			entry := fmt.Sprintf("entry %04d\n", i)
+			if _, err := w.Write([]byte(entry)); err != nil {
+				t.Fatal(err)
+			}
+			expected = append(expected, entry)
+			if len(expected) > 3 {
+				expected = expected[1:]
+			}
+			i++
+		}
+		crash(w)
+		assertEntries(t, filename, expected...)
+	}
+}
+
+func TestWriteWithEntryTooLarge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "teste.log")
+	w, err := Open(filename, Options{Size: MinSize})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if _, err := w.Write([]byte("kept\n")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(bytes.Repeat([]byte("x"), MinSize-entryHeaderSize)); err != ErrEntryTooLarge {
+		t.Fatalf("Expected %v, but received %v", ErrEntryTooLarge, err)
+	}
+	assertEntries(t, filename, "kept\n")
+}
+
+func TestOpenWithInvalidFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "teste.log")
+	if _, err := Open(filename, Options{Size: MinSize - 1}); err != ErrInvalidSize {
+		t.Fatalf("Expected %v, but received %v", ErrInvalidSize, err)
+	}
+	w, err := Open(filename, Options{Size: MinSize})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	if _, err := Open(filename, Options{Size: 2 * MinSize}); err != ErrSizeMismatch {
+		t.Fatalf("Expected %v, but received %v", ErrSizeMismatch, err)
+	}
+	plain := filepath.Join(dir, "plain.log")
+	if err := ioutil.WriteFile(plain, []byte("not a circular file\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(plain, Options{Size: MinSize}); err != ErrInvalidFile {
+		t.Fatalf("Expected %v, but received %v", ErrInvalidFile, err)
+	}
+}
+
+// crash stops the writer without writing the header
+func crash(w *Writer) {
+	w.mux.Lock()
+	w.closed = true
+	w.file.Close()
+	w.mux.Unlock()
+	close(w.stop)
+	<-w.done
+}
+
+func readFileHeader(t *testing.T, filename string) []byte {
+	t.Helper()
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content[:HeaderSize]
+}
+
+func assertEntries(t *testing.T, filename string, expected ...string) {
+	t.Helper()
+	r, err := NewReader(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var entries []string
+	for {
+		entry, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, string(entry))
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected %q, but found %q", expected, entries)
+	}
+	for i := range entries {
+		if entries[i] != expected[i] {
+			t.Fatalf("Expected %q, but found %q", expected, entries)
+		}
+	}
+}
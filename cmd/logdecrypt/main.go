@@ -0,0 +1,50 @@
+// Command logdecrypt decrypts the log files encrypted by the rotating loggers.
+//
+// Usage:
+//
+//	logdecrypt -key-file /etc/app/log.key app-20261017.log.gz.enc | zcat
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Murilovisque/logs/v3/rotatingfile"
+)
+
+func main() {
+	keyFile := flag.String("key-file", "", "file with the key, as 32 raw bytes or 64 hex characters")
+	output := flag.String("o", "", "output file, the standard output when empty")
+	flag.Parse()
+	if *keyFile == "" || flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: logdecrypt -key-file <key file> [-o <output>] <encrypted file>...")
+		os.Exit(2)
+	}
+	if err := run(rotatingfile.KeyFile(*keyFile), *output, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "logdecrypt:", err)
+		os.Exit(1)
+	}
+}
+
+// run decrypts the files in order to the output
+func run(keys rotatingfile.KeySource, output string, filenames []string) error {
+	var dst io.Writer = os.Stdout
+	if output != "" {
+		f, err := os.OpenFile(output, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		dst = f
+	}
+	buffered := bufio.NewWriter(dst)
+	for _, filename := range filenames {
+		if err := rotatingfile.DecryptFile(buffered, filename, keys); err != nil {
+			return fmt.Errorf("%s: %v", filename, err)
+		}
+	}
+	return buffered.Flush()
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Murilovisque/logs/v3/internal/encryptor"
+	"github.com/Murilovisque/logs/v3/rotatingfile"
+)
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logdecrypt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	key := rotatingfile.StaticKey(bytes.Repeat([]byte{1}, 32))
+	var filenames []string
+	for i, content := range []string{"first\n", "second\n"} {
+		var buf bytes.Buffer
+		w, err := encryptor.NewWriter(&buf, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+		w.Close()
+		filename := filepath.Join(dir, string(rune('a'+i))+".log.enc")
+		if err := ioutil.WriteFile(filename, buf.Bytes(), 0600); err != nil {
+			t.Fatal(err)
+		}
+		filenames = append(filenames, filename)
+	}
+	output := filepath.Join(dir, "out.log")
+	if err := run(key, output, filenames); err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "first\nsecond\n" {
+		t.Fatalf("Unexpected decrypted content %q", content)
+	}
+	if err := run(key, output, []string{output}); err == nil {
+		t.Fatal("Expected an error decrypting a plain file")
+	}
+}
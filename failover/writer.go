@@ -0,0 +1,376 @@
+// Package failover writes to a primary sink and, when it fails, to the first fallback that works, switching back
+// to the primary once probing it succeeds
+package failover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Murilovisque/logs/v3/rotatingfile"
+)
+
+// DefaultProbeInterval is how often the primary sink is probed when ProbeInterval is zero
+const DefaultProbeInterval = 30 * time.Second
+
+var (
+	ErrInvalidProbeInterval = errors.New("probe interval is less than zero")
+	ErrClosed               = errors.New("failover writer is closed")
+)
+
+// Sink is a destination of the Writer
+type Sink struct {
+	// Name identifies the sink in the SwitchEvent
+	Name string
+	// Open opens the writer of the sink. It is called again when probing a primary that could not be opened. The
+	// writer is closed by Writer.Close when it is an io.Closer
+	Open func() (io.Writer, error)
+}
+
+// WriterSink is a sink of a writer already opened, e.g. os.Stderr. The writer is never closed by the Writer
+func WriterSink(name string, w io.Writer) Sink {
+	return Sink{Name: name, Open: func() (io.Writer, error) {
+		return struct{ io.Writer }{w}, nil
+	}}
+}
+
+// RotatingFileSink is a sink of a rotatingfile.Writer, e.g. in a secondary directory
+func RotatingFileSink(name, filename string, opts rotatingfile.Options) Sink {
+	return Sink{Name: name, Open: func() (io.Writer, error) {
+		return rotatingfile.New(filename, opts)
+	}}
+}
+
+// SwitchEvent reports the Writer switching from a sink to another
+type SwitchEvent struct {
+	From string
+	To   string
+	// Err is the failure of From, nil when switching back to the primary after probing it
+	Err  error
+	Time time.Time
+}
+
+// Options configures a Writer
+type Options struct {
+	// ProbeInterval is how often a write is tried on the primary sink while writing to a fallback. Zero means
+	// DefaultProbeInterval
+	ProbeInterval time.Duration
+	// OnSwitch receives the switches between the sinks, in order, from a goroutine of the Writer, so it may write to
+	// the Writer, e.g. logging the switch through the same logger, but it must not block. The switches of New are
+	// received before it returns and the ones not received yet when closing are received before Close returns. Nil
+	// writes them to os.Stderr
+	OnSwitch func(SwitchEvent)
+	// Clock provides the time, e.g. a fake one in tests. Nil uses the system time
+	Clock rotatingfile.Clock
+}
+
+type sink struct {
+	Sink
+	w io.Writer
+}
+
+// Writer writes to the primary sink, or to the first fallback that works after the primary fails. A failed write
+// is written to the next sink from the first byte not written, so entries are neither lost nor duplicated while any
+// sink works
+type Writer struct {
+	mux           sync.Mutex
+	sinks         []*sink
+	active        int
+	lastProbe     time.Time
+	probing       bool
+	probeInterval time.Duration
+	onSwitch      func(SwitchEvent)
+	clock         rotatingfile.Clock
+	closed        bool
+	closeDone     chan struct{}
+	switches      []SwitchEvent
+	switchQueued  chan struct{}
+	stopReporting chan struct{}
+	reportDone    chan struct{}
+}
+
+// New opens the primary sink or, when it fails, the first fallback that opens
+func New(primary Sink, fallbacks []Sink, opts Options) (*Writer, error) {
+	if opts.ProbeInterval < 0 {
+		return nil, ErrInvalidProbeInterval
+	}
+	w := Writer{
+		probeInterval: opts.ProbeInterval,
+		onSwitch:      opts.OnSwitch,
+		clock:         opts.Clock,
+		closeDone:     make(chan struct{}),
+		switchQueued:  make(chan struct{}, 1),
+		stopReporting: make(chan struct{}),
+		reportDone:    make(chan struct{}),
+	}
+	if w.probeInterval == 0 {
+		w.probeInterval = DefaultProbeInterval
+	}
+	if w.onSwitch == nil {
+		w.onSwitch = reportToStderr
+	}
+	for _, s := range append([]Sink{primary}, fallbacks...) {
+		w.sinks = append(w.sinks, &sink{Sink: s})
+	}
+	var err error
+	for i, s := range w.sinks {
+		if s.w, err = s.Open(); err == nil {
+			w.active = i
+			w.lastProbe = w.now()
+			go reportingSwitches(&w)
+			return &w, nil
+		}
+		s.w = nil
+		if i+1 < len(w.sinks) {
+			w.onSwitch(w.switchEvent(i, i+1, err))
+		}
+	}
+	return nil, err
+}
+
+// Write writes p to the active sink, switching to the next ones while it fails. It returns the error of the last
+// sink when all of them fail
+func (w *Writer) Write(p []byte) (int, error) {
+	probe := w.startProbe()
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	if w.closed {
+		return 0, ErrClosed
+	}
+	written := 0
+	if probe {
+		w.probing = false
+		if primary := w.sinks[0]; w.active > 0 && primary.w != nil {
+			n, err := primary.w.Write(p)
+			if err == nil {
+				w.switched(w.active, 0, nil)
+				w.active = 0
+				return n, nil
+			}
+			written = n
+		}
+	}
+	var err error
+	for i := w.active; i < len(w.sinks); i++ {
+		var n int
+		n, err = w.writeTo(i, p[written:])
+		written += n
+		if err == nil {
+			if i != w.active {
+				w.active = i
+				w.lastProbe = w.now()
+			}
+			return written, nil
+		}
+		if i+1 < len(w.sinks) {
+			w.switched(i, i+1, err)
+		}
+	}
+	return written, err
+}
+
+// startProbe returns whether the write must be tried on the primary, at most once per probe interval and by a single
+// write at a time. A primary that could not be opened before is opened without the lock, so a slow Open does not
+// block the writes to the fallback
+func (w *Writer) startProbe() bool {
+	w.mux.Lock()
+	if w.closed || w.active == 0 || w.probing || w.now().Sub(w.lastProbe) < w.probeInterval {
+		w.mux.Unlock()
+		return false
+	}
+	w.probing = true
+	w.lastProbe = w.now()
+	primary := w.sinks[0]
+	opened := primary.w != nil
+	w.mux.Unlock()
+	if opened {
+		return true
+	}
+	sw, err := primary.Open()
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	if err == nil && w.closed {
+		if c, ok := sw.(io.Closer); ok {
+			c.Close()
+		}
+	}
+	if err != nil || w.closed {
+		w.probing = false
+		return false
+	}
+	primary.w = sw
+	return true
+}
+
+// Active returns the name of the sink being written
+func (w *Writer) Active() string {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	return w.sinks[w.active].Name
+}
+
+// Sync commits the entries written to the active sink, when it can be synced
+func (w *Writer) Sync() error {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	if w.closed {
+		return ErrClosed
+	}
+	if s, ok := w.sinks[w.active].w.(interface{ Sync() error }); ok {
+		return s.Sync()
+	}
+	return nil
+}
+
+// Close closes the writers of the sinks opened, returning the first error. The first call of Close or Shutdown
+// closes the writer, the other ones wait the same closing and return ErrClosed. The writes after it return ErrClosed
+func (w *Writer) Close() error {
+	writers, first := w.startClosing()
+	if !first {
+		<-w.closeDone
+		return ErrClosed
+	}
+	defer close(w.closeDone)
+	close(w.stopReporting)
+	var err error
+	for _, sw := range writers {
+		if c, ok := sw.(io.Closer); ok {
+			if closeErr := c.Close(); err == nil {
+				err = closeErr
+			}
+		}
+	}
+	<-w.reportDone
+	return err
+}
+
+// Shutdown closes the writers of the sinks opened as Close, shutting down the ones that can be, e.g. a
+// rotatingfile.Writer, with ctx. It returns ctx.Err() when ctx is done before the closing of another call
+func (w *Writer) Shutdown(ctx context.Context) error {
+	writers, first := w.startClosing()
+	if !first {
+		select {
+		case <-w.closeDone:
+			return ErrClosed
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	defer close(w.closeDone)
+	close(w.stopReporting)
+	var err error
+	for _, sw := range writers {
+		var closeErr error
+		if sd, ok := sw.(shutdowner); ok {
+			closeErr = sd.Shutdown(ctx)
+		} else if c, ok := sw.(io.Closer); ok {
+			closeErr = c.Close()
+		}
+		if err == nil {
+			err = closeErr
+		}
+	}
+	select {
+	case <-w.reportDone:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return err
+}
+
+// startClosing marks the writer closed on the first call, returning the writers of the sinks to close. They are
+// closed without the lock, so the writes return ErrClosed instead of waiting the closing
+func (w *Writer) startClosing() ([]io.Writer, bool) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	if w.closed {
+		return nil, false
+	}
+	w.closed = true
+	var writers []io.Writer
+	for _, s := range w.sinks {
+		if s.w != nil {
+			writers = append(writers, s.w)
+		}
+	}
+	return writers, true
+}
+
+type shutdowner interface {
+	Shutdown(ctx context.Context) error
+}
+
+// writeTo writes p to the sink i, opening it first if it could not be opened before
+func (w *Writer) writeTo(i int, p []byte) (int, error) {
+	s := w.sinks[i]
+	if s.w == nil {
+		var err error
+		if s.w, err = s.Open(); err != nil {
+			s.w = nil
+			return 0, err
+		}
+	}
+	return s.w.Write(p)
+}
+
+// switched queues the switch to be reported by reportingSwitches, as the writes may come with a lock held, e.g. the
+// one of the log package, that OnSwitch takes when logging the switch
+func (w *Writer) switched(from, to int, err error) {
+	w.switches = append(w.switches, w.switchEvent(from, to, err))
+	select {
+	case w.switchQueued <- struct{}{}:
+	default:
+	}
+}
+
+func (w *Writer) switchEvent(from, to int, err error) SwitchEvent {
+	return SwitchEvent{From: w.sinks[from].Name, To: w.sinks[to].Name, Err: err, Time: w.now()}
+}
+
+func reportingSwitches(w *Writer) {
+	defer close(w.reportDone)
+	for {
+		select {
+		case <-w.switchQueued:
+			w.reportSwitches()
+		case <-w.stopReporting:
+			w.reportSwitches()
+			return
+		}
+	}
+}
+
+// reportSwitches calls OnSwitch without the lock, so it can write to the Writer
+func (w *Writer) reportSwitches() {
+	for {
+		w.mux.Lock()
+		switches := w.switches
+		w.switches = nil
+		w.mux.Unlock()
+		if len(switches) == 0 {
+			return
+		}
+		for _, e := range switches {
+			w.onSwitch(e)
+		}
+	}
+}
+
+func (w *Writer) now() time.Time {
+	if w.clock == nil {
+		return time.Now()
+	}
+	return w.clock.Now()
+}
+
+func reportToStderr(e SwitchEvent) {
+	if e.Err == nil {
+		fmt.Fprintf(os.Stderr, "%s failover: switched back from %s to %s\n", e.Time.Format(time.RFC3339), e.From, e.To)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s failover: switched from %s to %s - Error: %s\n", e.Time.Format(time.RFC3339), e.From, e.To, e.Err)
+}
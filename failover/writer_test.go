@@ -0,0 +1,242 @@
+package failover
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/Murilovisque/logs/v3/rotatingfile/rotatingtest"
+)
+
+var errSinkTest = errors.New("sink failed")
+
+func TestWriterSwitchesToFallbackAndProbesPrimary(t *testing.T) {
+	clock := rotatingtest.NewFakeClock(time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC))
+	primary := &sinkTest{}
+	var fallback bytes.Buffer
+	var events []SwitchEvent
+	w, err := New(primary.sink("primary"), []Sink{WriterSink("fallback", &fallback)}, Options{
+		ProbeInterval: time.Minute,
+		OnSwitch:      func(e SwitchEvent) { events = append(events, e) },
+		Clock:         clock,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(t, w, "first\n")
+	primary.fail = true
+	write(t, w, "second\n")
+	primary.fail = false
+	write(t, w, "third\n")
+	if primary.String() != "first\n" || fallback.String() != "second\nthird\n" || w.Active() != "fallback" {
+		t.Fatalf("Unexpected content: primary '%s', fallback '%s'", primary.String(), fallback.String())
+	}
+	clock.Advance(time.Minute)
+	write(t, w, "fourth\n")
+	if primary.String() != "first\nfourth\n" || w.Active() != "primary" {
+		t.Fatalf("Expected the primary back, but found '%s'", primary.String())
+	}
+	// the switches are all received when Close returns
+	w.Close()
+	if len(events) != 2 || events[0].From != "primary" || events[0].To != "fallback" || events[0].Err != errSinkTest ||
+		events[1].From != "fallback" || events[1].To != "primary" || events[1].Err != nil {
+		t.Fatalf("Unexpected events %v", events)
+	}
+}
+
+func TestWriterOpensPrimaryWhenProbing(t *testing.T) {
+	clock := rotatingtest.NewFakeClock(time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC))
+	primary := &sinkTest{failOpen: true}
+	var fallback bytes.Buffer
+	var events []SwitchEvent
+	w, err := New(primary.sink("primary"), []Sink{WriterSink("fallback", &fallback)}, Options{
+		OnSwitch: func(e SwitchEvent) { events = append(events, e) },
+		Clock:    clock,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(t, w, "first\n")
+	clock.Advance(DefaultProbeInterval)
+	write(t, w, "second\n")
+	primary.failOpen = false
+	clock.Advance(DefaultProbeInterval)
+	write(t, w, "third\n")
+	if fallback.String() != "first\nsecond\n" || primary.String() != "third\n" {
+		t.Fatalf("Unexpected content: primary '%s', fallback '%s'", primary.String(), fallback.String())
+	}
+	w.Close()
+	if len(events) != 2 || events[0].Err != errSinkTest || events[1].To != "primary" {
+		t.Fatalf("Unexpected events %v", events)
+	}
+}
+
+func TestWriterProbesPrimaryWithoutBlockingWrites(t *testing.T) {
+	clock := rotatingtest.NewFakeClock(time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC))
+	primary := &sinkTest{}
+	opening := make(chan struct{})
+	release := make(chan struct{})
+	failOpen := true
+	var fallback bytes.Buffer
+	w, err := New(Sink{Name: "primary", Open: func() (io.Writer, error) {
+		if failOpen {
+			return nil, errSinkTest
+		}
+		close(opening)
+		<-release
+		return primary, nil
+	}}, []Sink{WriterSink("fallback", &fallback)}, Options{OnSwitch: func(SwitchEvent) {}, Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	failOpen = false
+	clock.Advance(DefaultProbeInterval)
+	probed := make(chan error, 1)
+	go func() {
+		_, err := w.Write([]byte("probe\n"))
+		probed <- err
+	}()
+	<-opening
+	write(t, w, "while probing\n")
+	close(release)
+	if err := <-probed; err != nil {
+		t.Fatal(err)
+	}
+	if fallback.String() != "while probing\n" || primary.String() != "probe\n" || w.Active() != "primary" {
+		t.Fatalf("Unexpected content: primary '%s', fallback '%s'", primary.String(), fallback.String())
+	}
+}
+
+func TestWriterWritesTheRestOfAPartialWrite(t *testing.T) {
+	primary := &sinkTest{partial: 3}
+	var fallback bytes.Buffer
+	w, err := New(primary.sink("primary"), []Sink{WriterSink("fallback", &fallback)}, Options{OnSwitch: func(SwitchEvent) {}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	n, err := w.Write([]byte("partial\n"))
+	if err != nil || n != len("partial\n") {
+		t.Fatalf("Expected %d bytes written, but received %d - Error: %v", len("partial\n"), n, err)
+	}
+	if primary.String() != "par" || fallback.String() != "tial\n" {
+		t.Fatalf("Unexpected content: primary '%s', fallback '%s'", primary.String(), fallback.String())
+	}
+}
+
+func TestWriterWhenEverySinkFails(t *testing.T) {
+	primary := &sinkTest{}
+	fallback := &sinkTest{}
+	w, err := New(primary.sink("primary"), []Sink{fallback.sink("fallback")}, Options{OnSwitch: func(SwitchEvent) {}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	primary.fail = true
+	fallback.fail = true
+	if _, err := w.Write([]byte("lost\n")); err != errSinkTest {
+		t.Fatalf("Expected %v, but received %v", errSinkTest, err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !primary.closed || !fallback.closed {
+		t.Fatal("Expected the sinks closed")
+	}
+	if _, err := w.Write([]byte("closed\n")); err != ErrClosed {
+		t.Fatalf("Expected %v, but received %v", ErrClosed, err)
+	}
+	primary.failOpen = true
+	if _, err := New(primary.sink("primary"), nil, Options{OnSwitch: func(SwitchEvent) {}}); err != errSinkTest {
+		t.Fatalf("Expected %v, but received %v", errSinkTest, err)
+	}
+	if _, err := New(primary.sink("primary"), nil, Options{ProbeInterval: -1}); err != ErrInvalidProbeInterval {
+		t.Fatalf("Expected %v, but received %v", ErrInvalidProbeInterval, err)
+	}
+}
+
+func TestShutdownDoesNotBlockWrites(t *testing.T) {
+	release := make(chan struct{})
+	primary := &blockingSink{release: release, shuttingDown: make(chan struct{})}
+	w, err := New(Sink{Name: "primary", Open: func() (io.Writer, error) { return primary, nil }}, nil, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- w.Shutdown(context.Background())
+	}()
+	<-primary.shuttingDown
+	if _, err := w.Write([]byte("closing\n")); err != ErrClosed {
+		t.Fatalf("Expected %v, but received %v", ErrClosed, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := w.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Expected %v, but received %v", context.DeadlineExceeded, err)
+	}
+	close(release)
+	if err := <-shutdown; err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != ErrClosed {
+		t.Fatalf("Expected %v, but received %v", ErrClosed, err)
+	}
+}
+
+func write(t *testing.T, w io.Writer, s string) {
+	t.Helper()
+	if _, err := w.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type sinkTest struct {
+	bytes.Buffer
+	fail     bool
+	failOpen bool
+	closed   bool
+	// partial is the amount of bytes written before failing, when greater than zero
+	partial int
+}
+
+func (s *sinkTest) sink(name string) Sink {
+	return Sink{Name: name, Open: func() (io.Writer, error) {
+		if s.failOpen {
+			return nil, errSinkTest
+		}
+		return s, nil
+	}}
+}
+
+func (s *sinkTest) Write(p []byte) (int, error) {
+	if s.fail {
+		return 0, errSinkTest
+	}
+	if s.partial > 0 && len(p) > s.partial {
+		n, _ := s.Buffer.Write(p[:s.partial])
+		return n, errSinkTest
+	}
+	return s.Buffer.Write(p)
+}
+
+func (s *sinkTest) Close() error {
+	s.closed = true
+	return nil
+}
+
+// blockingSink blocks its Shutdown until release is closed
+type blockingSink struct {
+	bytes.Buffer
+	release      chan struct{}
+	shuttingDown chan struct{}
+}
+
+func (s *blockingSink) Shutdown(ctx context.Context) error {
+	close(s.shuttingDown)
+	<-s.release
+	return nil
+}
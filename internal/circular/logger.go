@@ -0,0 +1,93 @@
+package circular
+
+import (
+	"context"
+	"io"
+	"log"
+	"sync"
+
+	"github.com/Murilovisque/logs/v3/circularfile"
+	logs "github.com/Murilovisque/logs/v3/internal"
+)
+
+type Options = circularfile.Options
+
+var ErrLoggerClosed = circularfile.ErrClosed
+
+// CircularLogger logs to a circularfile.Writer, each entry overwriting the oldest ones when the file is full
+type CircularLogger struct {
+	writer    *circularfile.Writer
+	closeOnce sync.Once
+	closeDone chan struct{}
+	closeErr  error
+	logs.SimpleLogger
+}
+
+func NewCircularLogger(level logs.LoggerLevelMode, filename string, opts Options, fixedValues ...logs.FieldValue) (*CircularLogger, error) {
+	w, err := circularfile.Open(filename, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &CircularLogger{
+		writer:       w,
+		SimpleLogger: logs.SimpleLogger{FieldsValues: fixedValues[:], LevelSelected: level},
+	}, nil
+}
+
+func (cl *CircularLogger) Init() {
+	cl.SimpleLogger.Init()
+	log.SetOutput(cl)
+}
+
+// Write returns ErrLoggerClosed after the logger is closed, the entry is dropped
+func (cl *CircularLogger) Write(p []byte) (int, error) {
+	n, err := cl.writer.Write(p)
+	if err == ErrLoggerClosed {
+		return n, err
+	}
+	cl.Counters().CountWrite(n, err)
+	return n, err
+}
+
+func (cl *CircularLogger) SetWriter(writer io.Writer) {
+	log.SetOutput(cl)
+}
+
+// Shutdown syncs and closes the file as Close, waiting until the closing is done or ctx is done, when it returns
+// ctx.Err() and the closing goes on in background. It can be called many times, returning ErrLoggerClosed when it is
+// not the first call of Close or Shutdown
+func (cl *CircularLogger) Shutdown(ctx context.Context) error {
+	first := cl.startClosing()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	select {
+	case <-cl.closeDone:
+		if !first {
+			return ErrLoggerClosed
+		}
+		return cl.closeErr
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close syncs and closes the file. The entries logged after it are dropped
+func (cl *CircularLogger) Close() {
+	cl.startClosing()
+	<-cl.closeDone
+}
+
+// startClosing closes the file in background on the first call, returning whether it was the first one
+func (cl *CircularLogger) startClosing() bool {
+	first := false
+	cl.closeOnce.Do(func() {
+		first = true
+		cl.closeDone = make(chan struct{})
+		go func() {
+			cl.closeErr = cl.writer.Close()
+			close(cl.closeDone)
+		}()
+	})
+	return first
+}
@@ -0,0 +1,105 @@
+package circular
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Murilovisque/logs/v3/circularfile"
+	logs "github.com/Murilovisque/logs/v3/internal"
+)
+
+func TestCircularLoggerKeepsLastEntries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "teste.log")
+	cl, err := NewCircularLogger(logs.LogInfoMode, filename, Options{Size: 120})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cl.Init()
+	for _, m := range []string{"first", "second", "third", "fourth"} {
+		cl.Info(m)
+	}
+	cl.Debug("dropped")
+	cl.Close()
+	r, err := circularfile.NewReader(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var buf bytes.Buffer
+	if _, err := r.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	content := buf.String()
+	if strings.Contains(content, "first") || strings.Contains(content, "dropped") || !strings.HasSuffix(content, "INFO * fourth\n") {
+		t.Fatalf("Expected only the last entries, but found '%s'", content)
+	}
+}
+
+func TestCircularLoggerShutdownIsIdempotent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cl, err := NewCircularLogger(logs.LogInfoMode, filepath.Join(dir, "teste.log"), Options{Size: circularfile.MinSize})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cl.Init()
+	if err := cl.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := cl.Shutdown(context.Background()); err != ErrLoggerClosed {
+		t.Fatalf("Expected %v, but received %v", ErrLoggerClosed, err)
+	}
+	if _, err := cl.Write([]byte("after\n")); err != ErrLoggerClosed {
+		t.Fatalf("Expected %v, but received %v", ErrLoggerClosed, err)
+	}
+}
+
+func TestCircularLoggerShutdownWithExpiredContext(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "teste.log")
+	cl, err := NewCircularLogger(logs.LogInfoMode, filename, Options{Size: circularfile.MinSize})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cl.Init()
+	cl.Info("teste")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := cl.Shutdown(ctx); err != context.Canceled {
+		t.Fatalf("Expected %v, but received %v", context.Canceled, err)
+	}
+	// the closing goes on in background
+	cl.Close()
+	if err := cl.Shutdown(context.Background()); err != ErrLoggerClosed {
+		t.Fatalf("Expected %v, but received %v", ErrLoggerClosed, err)
+	}
+	r, err := circularfile.NewReader(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var buf bytes.Buffer
+	if _, err := r.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(buf.String(), "INFO * teste\n") {
+		t.Fatalf("Expected the entry written before closing, but found '%s'", buf.String())
+	}
+}
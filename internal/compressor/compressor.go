@@ -2,37 +2,213 @@ package compressor
 
 import (
 	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"errors"
 	"io"
 	"os"
 	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Murilovisque/logs/v3/internal/fileutil"
 )
 
 const (
-	ZipExtension      = ".zip"
-	ZipExtensionRegex = "\\.zip"
+	ZipExtension       = ".zip"
+	ZipExtensionRegex  = "\\.zip"
+	GzipExtension      = ".gz"
+	GzipExtensionRegex = "\\.gz"
+	TempExtension      = ".tmp"
+)
+
+var (
+	ErrInvalidCompressionLevel   = errors.New("invalid compression level")
+	ErrArchiveVerificationFailed = errors.New("compressed content does not match the source file")
+	builtinExtensions            = []string{ZipExtension, GzipExtension}
 )
 
-func CompressFile(fileNametoCompress string) error {
-	compressedName := fileNametoCompress + ZipExtension
-	compressFile, err := os.Create(compressedName)
+// Compressor produces the archive written in place of a rotated log file
+type Compressor interface {
+	// Extension is appended to the compressed file name, e.g. ".gz"
+	Extension() string
+	// NewWriter returns a writer that compresses everything written to it into w. name is the base name of the source file
+	NewWriter(w io.Writer, name string) (io.WriteCloser, error)
+	// NewReader returns a reader of the decompressed content of the archive r with the given size
+	NewReader(r io.ReaderAt, size int64) (io.ReadCloser, error)
+}
+
+type Gzip struct {
+	level int
+}
+
+func NewGzip(level int) (*Gzip, error) {
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		return nil, ErrInvalidCompressionLevel
+	}
+	return &Gzip{level: level}, nil
+}
+
+func (g *Gzip) Extension() string {
+	return GzipExtension
+}
+
+func (g *Gzip) NewWriter(w io.Writer, name string) (io.WriteCloser, error) {
+	gw, err := gzip.NewWriterLevel(w, g.level)
+	if err != nil {
+		return nil, err
+	}
+	gw.Name = name
+	return gw, nil
+}
+
+func (g *Gzip) NewReader(r io.ReaderAt, size int64) (io.ReadCloser, error) {
+	return gzip.NewReader(io.NewSectionReader(r, 0, size))
+}
+
+type Zip struct{}
+
+func (z *Zip) Extension() string {
+	return ZipExtension
+}
+
+func (z *Zip) NewWriter(w io.Writer, name string) (io.WriteCloser, error) {
+	zipWriter := zip.NewWriter(w)
+	fileZipWriter, err := zipWriter.Create(name)
+	if err != nil {
+		zipWriter.Close()
+		return nil, err
+	}
+	return &zipFileWriter{Writer: fileZipWriter, zipWriter: zipWriter}, nil
+}
+
+func (z *Zip) NewReader(r io.ReaderAt, size int64) (io.ReadCloser, error) {
+	zipReader, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	if len(zipReader.File) != 1 {
+		return nil, ErrArchiveVerificationFailed
+	}
+	return zipReader.File[0].Open()
+}
+
+type zipFileWriter struct {
+	io.Writer
+	zipWriter *zip.Writer
+}
+
+func (zw *zipFileWriter) Close() error {
+	return zw.zipWriter.Close()
+}
+
+// ExtensionsRegex returns a regex group alternative matching the built-in extensions and the extension of c, if any
+func ExtensionsRegex(c Compressor) string {
+	exts := Extensions(c)
+	quoted := make([]string, len(exts))
+	for i, ext := range exts {
+		quoted[i] = regexp.QuoteMeta(ext)
+	}
+	return strings.Join(quoted, "|")
+}
+
+// Extensions returns the built-in extensions and the extension of c, if any
+func Extensions(c Compressor) []string {
+	exts := builtinExtensions[:len(builtinExtensions):len(builtinExtensions)]
+	if c == nil {
+		return exts
+	}
+	for _, ext := range exts {
+		if ext == c.Extension() {
+			return exts
+		}
+	}
+	return append(exts, c.Extension())
+}
+
+// CompressFile compresses the file into a temporary archive, verifies it against the source, renames it
+// atomically to its final name and, only then, removes the source file
+func CompressFile(c Compressor, fileNametoCompress string, perms fileutil.Permissions) error {
+	compressedName := fileNametoCompress + c.Extension()
+	tempName := compressedName + TempExtension
+	sourceSum, sourceSize, err := writeTempArchive(c, fileNametoCompress, tempName, perms)
 	if err != nil {
+		os.Remove(tempName)
 		return err
 	}
-	defer compressFile.Close()
-	zipWriter := zip.NewWriter(compressFile)
-	defer zipWriter.Close()
-	baseCompressedName := path.Base(fileNametoCompress)
-	fileZipWriter, err := zipWriter.Create(baseCompressedName)
+	err = verifyArchive(c, tempName, sourceSum, sourceSize)
 	if err != nil {
-		os.Remove(compressedName)
+		os.Remove(tempName)
 		return err
 	}
-	fileToZip, err := os.Open(fileNametoCompress)
+	err = os.Rename(tempName, compressedName)
 	if err != nil {
-		os.Remove(compressedName)
+		os.Remove(tempName)
 		return err
 	}
-	defer fileToZip.Close()
-	_, err = io.Copy(fileZipWriter, fileToZip)
-	return err
+	fileutil.SyncDir(filepath.Dir(compressedName))
+	return os.Remove(fileNametoCompress)
+}
+
+// IsTempFile reports whether filename is a partial archive left by an interrupted compression
+func IsTempFile(filename string) bool {
+	return strings.HasSuffix(filename, TempExtension)
+}
+
+func writeTempArchive(c Compressor, fileNametoCompress, tempName string, perms fileutil.Permissions) ([]byte, int64, error) {
+	fileToCompress, err := os.Open(fileNametoCompress)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer fileToCompress.Close()
+	tempFile, err := perms.OpenFile(tempName, os.O_CREATE|os.O_TRUNC|os.O_WRONLY)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tempFile.Close()
+	compressWriter, err := c.NewWriter(tempFile, path.Base(fileNametoCompress))
+	if err != nil {
+		return nil, 0, err
+	}
+	hash := sha256.New()
+	size, err := io.Copy(compressWriter, io.TeeReader(fileToCompress, hash))
+	if err != nil {
+		compressWriter.Close()
+		return nil, 0, err
+	}
+	if err = compressWriter.Close(); err != nil {
+		return nil, 0, err
+	}
+	if err = tempFile.Sync(); err != nil {
+		return nil, 0, err
+	}
+	return hash.Sum(nil), size, tempFile.Close()
+}
+
+func verifyArchive(c Compressor, archiveName string, sourceSum []byte, sourceSize int64) error {
+	archive, err := os.Open(archiveName)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+	info, err := archive.Stat()
+	if err != nil {
+		return err
+	}
+	reader, err := c.NewReader(archive, info.Size())
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, reader)
+	if err != nil {
+		return err
+	}
+	if size != sourceSize || !bytes.Equal(hash.Sum(nil), sourceSum) {
+		return ErrArchiveVerificationFailed
+	}
+	return nil
 }
@@ -2,10 +2,14 @@ package compressor
 
 import (
 	"archive/zip"
+	"compress/gzip"
+	"io"
 	"io/ioutil"
 	"os"
 	"path"
 	"testing"
+
+	"github.com/Murilovisque/logs/v3/internal/fileutil"
 )
 
 func TestCompressFileToZip(t *testing.T) {
@@ -21,8 +25,14 @@ func TestCompressFileToZip(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	CompressFile(caminhoCompletoArqLog)
+	err = CompressFile(&Zip{}, caminhoCompletoArqLog, fileutil.Permissions{})
+	if err != nil {
+		t.Fatal(err)
+	}
 
+	if _, err := os.Stat(caminhoCompletoArqLog); !os.IsNotExist(err) {
+		t.Fatalf("Source file should be removed, but stat returned %v", err)
+	}
 	caminhoCompletoArqLogComprimido := caminhoCompletoArqLog + ".zip"
 	zipReader, err := zip.OpenReader(caminhoCompletoArqLogComprimido)
 	if err != nil {
@@ -43,3 +53,179 @@ func TestCompressFileToZip(t *testing.T) {
 		t.Fatalf("Should be %s but is %s", string(conteudoArq), string(conteudoZippedFile))
 	}
 }
+
+func TestCompressFileToGzip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	nomeArqLog := "teste.log"
+	caminhoCompletoArqLog := path.Join(dir, nomeArqLog)
+	conteudoArq := []byte("conteúdo")
+	err = ioutil.WriteFile(caminhoCompletoArqLog, conteudoArq, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewGzip(gzip.BestCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = CompressFile(c, caminhoCompletoArqLog, fileutil.Permissions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	arqComprimido, err := os.Open(caminhoCompletoArqLog + ".gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer arqComprimido.Close()
+	gzipReader, err := gzip.NewReader(arqComprimido)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gzipReader.Name != nomeArqLog {
+		t.Fatalf("Should be %s but is %s", nomeArqLog, gzipReader.Name)
+	}
+	conteudoGzipFile, err := ioutil.ReadAll(gzipReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(conteudoGzipFile) != string(conteudoArq) {
+		t.Fatalf("Should be %s but is %s", string(conteudoArq), string(conteudoGzipFile))
+	}
+}
+
+func TestNewGzipWithInvalidLevel(t *testing.T) {
+	for _, level := range []int{-3, 10} {
+		if _, err := NewGzip(level); err != ErrInvalidCompressionLevel {
+			t.Fatalf("Level %d should be invalid, but error is %v", level, err)
+		}
+	}
+}
+
+func TestExtensionsRegex(t *testing.T) {
+	tests := []struct {
+		c        Compressor
+		expected string
+	}{
+		{nil, "\\.zip|\\.gz"},
+		{&Zip{}, "\\.zip|\\.gz"},
+		{&Gzip{}, "\\.zip|\\.gz"},
+		{extCompressor(".log.bz2"), "\\.zip|\\.gz|\\.log\\.bz2"},
+	}
+	for _, test := range tests {
+		vl := ExtensionsRegex(test.c)
+		if vl != test.expected {
+			t.Fatalf("Expected %s, but received %s", test.expected, vl)
+		}
+	}
+}
+
+type extCompressor string
+
+func (c extCompressor) Extension() string {
+	return string(c)
+}
+
+func (c extCompressor) NewWriter(w io.Writer, name string) (io.WriteCloser, error) {
+	return nil, nil
+}
+
+func (c extCompressor) NewReader(r io.ReaderAt, size int64) (io.ReadCloser, error) {
+	return nil, nil
+}
+
+func TestCompressFileKeepsSourceWhenVerificationFails(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	caminhoCompletoArqLog := path.Join(dir, "teste.log")
+	err = ioutil.WriteFile(caminhoCompletoArqLog, []byte("conteúdo"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = CompressFile(&truncatingCompressor{}, caminhoCompletoArqLog, fileutil.Permissions{})
+	if err != ErrArchiveVerificationFailed {
+		t.Fatalf("Expected %v, but received %v", ErrArchiveVerificationFailed, err)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name() != "teste.log" {
+		t.Fatalf("Only the source file should remain, but found %v", files)
+	}
+}
+
+func TestIsTempFile(t *testing.T) {
+	tests := []struct {
+		vl       string
+		expected bool
+	}{
+		{"/var/log/teste-20121207.log.gz.tmp", true},
+		{"/var/log/teste-20121207.log.zip.tmp", true},
+		{"/var/log/teste-20121207.log.gz", false},
+		{"/var/log/teste-20121207.log", false},
+	}
+	for _, test := range tests {
+		if vl := IsTempFile(test.vl); vl != test.expected {
+			t.Fatalf("Expected %v for %s, but received %v", test.expected, test.vl, vl)
+		}
+	}
+}
+
+// truncatingCompressor drops the last byte of the content, simulating a corrupted archive
+type truncatingCompressor struct {
+	Gzip
+}
+
+func (c *truncatingCompressor) NewWriter(w io.Writer, name string) (io.WriteCloser, error) {
+	return &truncatingWriter{w: w}, nil
+}
+
+func (c *truncatingCompressor) NewReader(r io.ReaderAt, size int64) (io.ReadCloser, error) {
+	return ioutil.NopCloser(io.NewSectionReader(r, 0, size)), nil
+}
+
+type truncatingWriter struct {
+	w       io.Writer
+	content []byte
+}
+
+func (tw *truncatingWriter) Write(p []byte) (int, error) {
+	tw.content = append(tw.content, p...)
+	return len(p), nil
+}
+
+func (tw *truncatingWriter) Close() error {
+	_, err := tw.w.Write(tw.content[:len(tw.content)-1])
+	return err
+}
+
+func TestCompressFileWithPermissions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	caminhoCompletoArqLog := path.Join(dir, "teste.log")
+	err = ioutil.WriteFile(caminhoCompletoArqLog, []byte("conteúdo"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = CompressFile(&Zip{}, caminhoCompletoArqLog, fileutil.Permissions{FileMode: 0600})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(caminhoCompletoArqLog + ZipExtension)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode() != 0600 {
+		t.Fatalf("Should be %v but is %v", os.FileMode(0600), info.Mode())
+	}
+}
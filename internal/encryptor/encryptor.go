@@ -0,0 +1,269 @@
+package encryptor
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"regexp"
+)
+
+const (
+	Extension      = ".enc"
+	ExtensionRegex = "\\.enc"
+	KeySize        = 32
+	ChunkSize      = 64 * 1024
+	magic          = "GOLOGENC"
+	version        = 1
+	noncePrefixLen = 8
+	headerLen      = len(magic) + 1 + noncePrefixLen
+	chunkHeaderLen = 5
+	lastChunkFlag  = 1
+)
+
+var (
+	ErrInvalidKey       = errors.New("encryption key must have 32 bytes")
+	ErrInvalidFormat    = errors.New("file is not an encrypted log file")
+	ErrTruncated        = errors.New("encrypted log file is truncated")
+	ErrTooManyChunks    = errors.New("encrypted log file has too many chunks")
+	ErrUnexpectedChunks = errors.New("encrypted log file has data after the last chunk")
+)
+
+// KeySource provides the AES-256 key used to encrypt and decrypt the files
+type KeySource interface {
+	Key() ([]byte, error)
+}
+
+// StaticKey is a KeySource returning the same 32 bytes key
+type StaticKey []byte
+
+func (k StaticKey) Key() ([]byte, error) {
+	if len(k) != KeySize {
+		return nil, ErrInvalidKey
+	}
+	return k, nil
+}
+
+// KeyFile is a KeySource reading the key from a file, either as 32 raw bytes or 64 hex characters. It is read on
+// each use, so the key can be replaced without restarting the process
+type KeyFile string
+
+func (k KeyFile) Key() ([]byte, error) {
+	content, err := ioutil.ReadFile(string(k))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) == KeySize {
+		return content, nil
+	}
+	key, err := hex.DecodeString(string(bytes.TrimSpace(content)))
+	if err != nil || len(key) != KeySize {
+		return nil, ErrInvalidKey
+	}
+	return key, nil
+}
+
+// AESGCM encrypts the files with AES-256-GCM in chunks, so they are streamed without being loaded in memory. It
+// implements compressor.Compressor, so the files are encrypted with the same guarantees of the compression
+type AESGCM struct {
+	Keys KeySource
+}
+
+func (e *AESGCM) Extension() string {
+	return Extension
+}
+
+func (e *AESGCM) NewWriter(w io.Writer, name string) (io.WriteCloser, error) {
+	return NewWriter(w, e.Keys)
+}
+
+func (e *AESGCM) NewReader(r io.ReaderAt, size int64) (io.ReadCloser, error) {
+	reader, err := NewReader(io.NewSectionReader(r, 0, size), e.Keys)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(reader), nil
+}
+
+// ExtensionsRegex returns a regex matching the extensions in compressExtensionsRegex optionally followed by the
+// encrypted extension, e.g. .gz, .gz.enc or .enc
+func ExtensionsRegex(compressExtensionsRegex string) string {
+	return "(?:" + compressExtensionsRegex + ")?(?:" + regexp.QuoteMeta(Extension) + ")?"
+}
+
+func newAEAD(keys KeySource) (cipher.AEAD, error) {
+	key, err := keys.Key()
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != KeySize {
+		return nil, ErrInvalidKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Writer encrypts the content written to it. The file starts with a header holding the magic, the version and the
+// nonce prefix, followed by chunks of a flag, the ciphertext length and the ciphertext. Each chunk nonce is the
+// prefix plus the chunk counter, and the header and the flag are authenticated, so a reordered, truncated or
+// extended file fails to decrypt
+type Writer struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	header  []byte
+	counter uint32
+	buf     []byte
+	closed  bool
+}
+
+// NewWriter writes the header to w and returns the Writer encrypting to it
+func NewWriter(w io.Writer, keys KeySource) (*Writer, error) {
+	aead, err := newAEAD(keys)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, headerLen)
+	copy(header, magic)
+	header[len(magic)] = version
+	if _, err := io.ReadFull(rand.Reader, header[len(magic)+1:]); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w, aead: aead, header: header, buf: make([]byte, 0, ChunkSize)}, nil
+}
+
+func (ew *Writer) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := copy(ew.buf[len(ew.buf):cap(ew.buf)], p)
+		ew.buf = ew.buf[:len(ew.buf)+n]
+		p = p[n:]
+		written += n
+		if len(ew.buf) == cap(ew.buf) {
+			if err := ew.writeChunk(0); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// Close writes the last chunk, it doesn't close the underlying writer
+func (ew *Writer) Close() error {
+	if ew.closed {
+		return nil
+	}
+	ew.closed = true
+	return ew.writeChunk(lastChunkFlag)
+}
+
+func (ew *Writer) writeChunk(flag byte) error {
+	if ew.counter == ^uint32(0) {
+		return ErrTooManyChunks
+	}
+	chunkHeader := make([]byte, chunkHeaderLen)
+	chunkHeader[0] = flag
+	binary.BigEndian.PutUint32(chunkHeader[1:], uint32(len(ew.buf)+ew.aead.Overhead()))
+	ciphertext := ew.aead.Seal(nil, nonce(ew.header, ew.counter), ew.buf, additionalData(ew.header, flag))
+	ew.counter++
+	ew.buf = ew.buf[:0]
+	if _, err := ew.w.Write(chunkHeader); err != nil {
+		return err
+	}
+	_, err := ew.w.Write(ciphertext)
+	return err
+}
+
+// Reader decrypts the content written by Writer
+type Reader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	header  []byte
+	counter uint32
+	plain   []byte
+	done    bool
+}
+
+// NewReader reads the header from r and returns the Reader decrypting it
+func NewReader(r io.Reader, keys KeySource) (*Reader, error) {
+	aead, err := newAEAD(keys)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, headerLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, ErrInvalidFormat
+	}
+	if string(header[:len(magic)]) != magic || header[len(magic)] != version {
+		return nil, ErrInvalidFormat
+	}
+	return &Reader{r: bufio.NewReader(r), aead: aead, header: header}, nil
+}
+
+func (er *Reader) Read(p []byte) (int, error) {
+	for len(er.plain) == 0 {
+		if er.done {
+			return 0, io.EOF
+		}
+		if err := er.readChunk(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, er.plain)
+	er.plain = er.plain[n:]
+	return n, nil
+}
+
+func (er *Reader) readChunk() error {
+	chunkHeader := make([]byte, chunkHeaderLen)
+	if _, err := io.ReadFull(er.r, chunkHeader); err != nil {
+		return ErrTruncated
+	}
+	flag := chunkHeader[0]
+	size := binary.BigEndian.Uint32(chunkHeader[1:])
+	if flag > lastChunkFlag || size < uint32(er.aead.Overhead()) || size > uint32(ChunkSize+er.aead.Overhead()) {
+		return ErrInvalidFormat
+	}
+	ciphertext := make([]byte, size)
+	if _, err := io.ReadFull(er.r, ciphertext); err != nil {
+		return ErrTruncated
+	}
+	plain, err := er.aead.Open(ciphertext[:0], nonce(er.header, er.counter), ciphertext, additionalData(er.header, flag))
+	if err != nil {
+		return err
+	}
+	er.counter++
+	er.plain = plain
+	if flag == lastChunkFlag {
+		er.done = true
+		if _, err := er.r.Peek(1); err != io.EOF {
+			return ErrUnexpectedChunks
+		}
+	}
+	return nil
+}
+
+func nonce(header []byte, counter uint32) []byte {
+	n := make([]byte, noncePrefixLen+4)
+	copy(n, header[len(magic)+1:])
+	binary.BigEndian.PutUint32(n[noncePrefixLen:], counter)
+	return n
+}
+
+func additionalData(header []byte, flag byte) []byte {
+	ad := make([]byte, len(header)+1)
+	copy(ad, header)
+	ad[len(header)] = flag
+	return ad
+}
@@ -0,0 +1,119 @@
+package encryptor
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncryptAndDecrypt(t *testing.T) {
+	key := newKey(t)
+	for _, size := range []int{0, 10, ChunkSize, 3*ChunkSize + 7} {
+		content := make([]byte, size)
+		if _, err := rand.Read(content); err != nil {
+			t.Fatal(err)
+		}
+		encrypted := encrypt(t, key, content)
+		reader, err := NewReader(bytes.NewReader(encrypted), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decrypted, err := ioutil.ReadAll(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(decrypted, content) {
+			t.Fatalf("Decrypted content of %d bytes doesn't match", size)
+		}
+	}
+}
+
+func TestDecryptInvalidFiles(t *testing.T) {
+	key := newKey(t)
+	encrypted := encrypt(t, key, make([]byte, 2*ChunkSize))
+	tampered := append([]byte{}, encrypted...)
+	tampered[len(tampered)-1] ^= 1
+	tests := []struct {
+		content  []byte
+		keys     KeySource
+		expected error
+	}{
+		{[]byte("plain text log file"), key, ErrInvalidFormat},
+		{encrypted[:len(encrypted)-ChunkSize], key, ErrTruncated},
+		{encrypted[:headerLen+chunkHeaderLen+ChunkSize+16], key, ErrTruncated},
+		{append(append([]byte{}, encrypted...), 0), key, ErrUnexpectedChunks},
+		{tampered, key, nil},
+		{encrypted, newKey(t), nil},
+	}
+	for i, tt := range tests {
+		reader, err := NewReader(bytes.NewReader(tt.content), tt.keys)
+		if err == nil {
+			_, err = io.Copy(ioutil.Discard, reader)
+		}
+		if err == nil || (tt.expected != nil && err != tt.expected) {
+			t.Fatalf("Test %d: expected error %v, but received %v", i, tt.expected, err)
+		}
+	}
+}
+
+func TestKeySources(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	key := newKey(t)
+	files := map[string][]byte{
+		"raw":   key,
+		"hex":   []byte(hex.EncodeToString(key) + "\n"),
+		"short": []byte("0102"),
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), content, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"raw", "hex"} {
+		k, err := KeyFile(filepath.Join(dir, name)).Key()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(k, key) {
+			t.Fatalf("Unexpected key read from the %s file", name)
+		}
+	}
+	if _, err := KeyFile(filepath.Join(dir, "short")).Key(); err != ErrInvalidKey {
+		t.Fatalf("Expected %v, but received %v", ErrInvalidKey, err)
+	}
+	if _, err := StaticKey([]byte("short")).Key(); err != ErrInvalidKey {
+		t.Fatalf("Expected %v, but received %v", ErrInvalidKey, err)
+	}
+}
+
+func newKey(t *testing.T) StaticKey {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func encrypt(t *testing.T, keys KeySource, content []byte) []byte {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,104 @@
+package fileutil
+
+import (
+	"os"
+	"path/filepath"
+)
+
+const (
+	DefaultFileMode os.FileMode = 0644
+	DefaultDirMode  os.FileMode = 0755
+)
+
+// Owner of the files and directories created, applied only when the process runs as root
+type Owner struct {
+	UID int
+	GID int
+}
+
+// Permissions applied to the files and directories created. The zero value uses the default modes and keeps the owner
+type Permissions struct {
+	FileMode os.FileMode
+	DirMode  os.FileMode
+	Owner    *Owner
+}
+
+func (p Permissions) fileMode() os.FileMode {
+	if p.FileMode == 0 {
+		return DefaultFileMode
+	}
+	return p.FileMode
+}
+
+func (p Permissions) dirMode() os.FileMode {
+	if p.DirMode == 0 {
+		return DefaultDirMode
+	}
+	return p.DirMode
+}
+
+// OpenFile opens the file with the flag, creating the missing directories. The mode and owner are applied when the
+// file is created, the mode regardless of the umask
+func (p Permissions) OpenFile(filename string, flag int) (*os.File, error) {
+	if err := p.MkdirAll(filepath.Dir(filename)); err != nil {
+		return nil, err
+	}
+	_, statErr := os.Stat(filename)
+	created := os.IsNotExist(statErr)
+	f, err := os.OpenFile(filename, flag, p.fileMode())
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		if err := p.apply(filename, p.fileMode()); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// MkdirAll creates the missing directories, applying the mode and owner to each one created
+func (p Permissions) MkdirAll(dir string) error {
+	if _, err := os.Stat(dir); err == nil {
+		return nil
+	}
+	parent := filepath.Dir(dir)
+	if parent != dir {
+		if err := p.MkdirAll(parent); err != nil {
+			return err
+		}
+	}
+	if err := os.Mkdir(dir, p.dirMode()); err != nil {
+		if os.IsExist(err) {
+			return nil
+		}
+		return err
+	}
+	return p.apply(dir, p.dirMode())
+}
+
+// Chown applies the owner to the file when the process runs as root
+func (p Permissions) Chown(filename string) error {
+	if p.Owner == nil || os.Geteuid() != 0 {
+		return nil
+	}
+	return os.Chown(filename, p.Owner.UID, p.Owner.GID)
+}
+
+func (p Permissions) apply(filename string, mode os.FileMode) error {
+	if err := os.Chmod(filename, mode); err != nil {
+		return err
+	}
+	return p.Chown(filename)
+}
+
+// SyncDir flushes the directory entries, making renames durable. It is not supported on every platform, so errors are ignored
+func SyncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
@@ -0,0 +1,36 @@
+package fileutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenFileKeepsModeOfExistingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "teste.log")
+	if err := ioutil.WriteFile(filename, []byte("x"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := Permissions{}.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	assertMode(t, filename, 0600)
+}
+
+func assertMode(t *testing.T, filename string, mode os.FileMode) {
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode() != mode {
+		t.Fatalf("Expected mode %v of %s, but received %v", mode, filename, info.Mode())
+	}
+}
@@ -0,0 +1,33 @@
+//go:build !windows
+// +build !windows
+
+package fileutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestOpenFileWithPermissions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	oldUmask := syscall.Umask(0077)
+	defer syscall.Umask(oldUmask)
+	p := Permissions{FileMode: 0640, DirMode: 0750}
+	filename := filepath.Join(dir, "a", "b", "teste.log")
+	f, err := p.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	assertMode(t, filename, 0640)
+	assertMode(t, filepath.Join(dir, "a"), os.ModeDir|0750)
+	assertMode(t, filepath.Join(dir, "a", "b"), os.ModeDir|0750)
+	assertMode(t, dir, os.ModeDir|0700)
+}
@@ -1,6 +1,8 @@
 package logs
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"log"
@@ -41,6 +43,8 @@ type Logger interface {
 	SetWriter(io.Writer)
 	Init()
 	FixedFieldsValues() []FieldValue
+	Stats() Stats
+	Shutdown(ctx context.Context) error
 	Close()
 }
 
@@ -52,9 +56,11 @@ type SimpleLogger struct {
 	logWarnEnabled  bool
 	logInfoEnabled  bool
 	logDebugEnabled bool
+	counters        *Counters
 }
 
 func (l *SimpleLogger) Init() {
+	l.Counters()
 	if l.FieldsValues == nil {
 		l.FieldsValues = []FieldValue{}
 	}
@@ -87,57 +93,67 @@ func anyLevelMatch(level LoggerLevelMode, allowedLevels []LoggerLevelMode) bool
 }
 
 func (l *SimpleLogger) Fatalf(message string, v ...interface{}) {
+	l.counters.CountEntry(LogFatalMode)
 	log.Fatal(l.buildFormatedMessage(LogFatalMode, message, v...))
 }
 
 func (l *SimpleLogger) Infof(message string, v ...interface{}) {
 	if l.logInfoEnabled {
+		l.counters.CountEntry(LogInfoMode)
 		log.Println(l.buildFormatedMessage(LogInfoMode, message, v...))
 	}
 }
 
 func (l *SimpleLogger) Errorf(message string, v ...interface{}) {
 	if l.logErrorEnabled {
+		l.counters.CountEntry(LogErrorMode)
 		log.Println(l.buildFormatedMessage(LogErrorMode, message, v...))
 	}
 }
 
 func (l *SimpleLogger) Debugf(message string, v ...interface{}) {
 	if l.logDebugEnabled {
+		l.counters.CountEntry(LogDebugMode)
 		log.Println(l.buildFormatedMessage(LogDebugMode, message, v...))
 	}
 }
 
 func (l *SimpleLogger) Warnf(message string, v ...interface{}) {
 	if l.logWarnEnabled {
+		l.counters.CountEntry(LogWarnMode)
 		log.Println(l.buildFormatedMessage(LogWarnMode, message, v...))
 	}
 }
 
 func (l *SimpleLogger) Fatal(message interface{}) {
+	l.counters.CountEntry(LogFatalMode)
 	log.Fatal(l.buildMessage(LogFatalMode, message))
 }
 
 func (l *SimpleLogger) Info(message interface{}) {
 	if l.logInfoEnabled {
+		l.counters.CountEntry(LogInfoMode)
 		log.Println(l.buildMessage(LogInfoMode, message))
 	}
 }
 
 func (l *SimpleLogger) Error(message interface{}) {
 	if l.logErrorEnabled {
+		l.counters.CountEntry(LogErrorMode)
 		log.Println(l.buildMessage(LogErrorMode, message))
 	}
 }
 
 func (l *SimpleLogger) Debug(message interface{}) {
 	if l.logDebugEnabled {
+		l.counters.CountEntry(LogDebugMode)
 		log.Println(l.buildMessage(LogDebugMode, message))
 	}
 }
 
 func (l *SimpleLogger) Warn(message interface{}) {
 	if l.logWarnEnabled {
+		l.counters.CountEntry(LogWarnMode)
 		log.Println(l.buildMessage(LogWarnMode, message))
 	}
 }
@@ -148,6 +164,30 @@ func (l *SimpleLogger) FixedFieldsValues() []FieldValue {
 
 func (l *SimpleLogger) Close() {}
 
+// Shutdown does nothing, the writer set with SetWriter is not owned by the logger
+func (l *SimpleLogger) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// Stats returns the entries logged per level and the writes to the writer set with SetWriter
+func (l *SimpleLogger) Stats() Stats {
+	return l.counters.Stats()
+}
+
+// Counters returns the counters of the logger, created on the first call. The loggers writing to their own sink
+// count the writes with it
+func (l *SimpleLogger) Counters() *Counters {
+	if l.counters == nil {
+		l.counters = &Counters{}
+	}
+	return l.counters
+}
+
+// Message returns the message as it is logged, without the prefix added by the log package
+func (l *SimpleLogger) Message(level LoggerLevelMode, message interface{}) string {
+	return l.buildMessage(level, message)
+}
+
 func (l *SimpleLogger) buildMessage(level LoggerLevelMode, message interface{}) string {
 	return fmt.Sprintf("%s%s%v", level, l.fixedLogMessage, message)
 }
@@ -157,7 +197,32 @@ func (l *SimpleLogger) buildFormatedMessage(level LoggerLevelMode, message strin
 }
 
 func (l *SimpleLogger) SetWriter(writer io.Writer) {
-	log.SetOutput(writer)
+	log.SetOutput(countingWriter{Writer: writer, counters: l.Counters()})
+}
+
+// EntryLevel returns the level of an entry built by a SimpleLogger, found after the prefix added by the log
+// package. It is false for the lines written directly with the log package
+func EntryLevel(p []byte) (LoggerLevelMode, bool) {
+	for start := 0; start < len(p); {
+		end := bytes.IndexByte(p[start:], ' ')
+		if end < 0 {
+			return "", false
+		}
+		end += start
+		messageFollows := bytes.HasPrefix(p[end:], []byte(" * "))
+		if messageFollows || bytes.HasPrefix(p[end:], []byte(" [")) {
+			for _, level := range LogsMode {
+				if string(p[start:end]) == string(level) {
+					return level, true
+				}
+			}
+		}
+		if messageFollows {
+			return "", false
+		}
+		start = end + 1
+	}
+	return "", false
 }
 
 type FieldValue struct {
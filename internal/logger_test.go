@@ -81,6 +81,27 @@ func TestShouldLogFormattedMessageWithFixedFields(t *testing.T) {
 	logWriter.assertLogMessage(t, "ERROR [reqid: 1] [idtperson: 2] * teste txt 10\n")
 }
 
+func TestEntryLevel(t *testing.T) {
+	tests := []struct {
+		vl    string
+		level LoggerLevelMode
+		ok    bool
+	}{
+		{"2026/10/17 12:00:00 INFO * teste\n", LogInfoMode, true},
+		{"2026/10/17 12:00:00 ERROR [reqid: 1] * teste\n", LogErrorMode, true},
+		{"FATAL * teste\n", LogFatalMode, true},
+		{"2026/10/17 12:00:00 raw line\n", "", false},
+		{"2026/10/17 12:00:00 raw * line ERROR * teste\n", "", false},
+		{"", "", false},
+	}
+	for _, test := range tests {
+		level, ok := EntryLevel([]byte(test.vl))
+		if level != test.level || ok != test.ok {
+			t.Fatalf("Expected %v %v for '%s', but received %v %v", test.level, test.ok, test.vl, level, ok)
+		}
+	}
+}
+
 func setup(fixedValues ...FieldValue) {
 	logWriter.lines = []string{}
 	sl = SimpleLogger{FieldsValues: fixedValues, LevelSelected: LogDebugMode}
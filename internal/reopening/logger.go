@@ -0,0 +1,239 @@
+package reopening
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	logs "github.com/Murilovisque/logs/v3/internal"
+	"github.com/Murilovisque/logs/v3/internal/fileutil"
+)
+
+var (
+	ErrInvalidCheckInterval = errors.New("check interval is less than zero")
+	ErrLoggerClosed         = errors.New("file logger is closed")
+)
+
+// Options configures a FileLogger
+type Options struct {
+	// ReopenOnSIGHUP reopens the file when the process receives SIGHUP, as expected by logrotate
+	ReopenOnSIGHUP bool
+	// CheckInterval is how often the file is checked to be moved or removed, reopening it when so. Zero disables the check
+	CheckInterval time.Duration
+	// FileMode of the file when created. Zero means 0644
+	FileMode os.FileMode
+	// DirMode of the directories created. Zero means 0755
+	DirMode os.FileMode
+	// Owner is applied to the file and directories created when the process runs as root. Nil keeps the owner
+	Owner *fileutil.Owner
+}
+
+// FileLogger writes to a file that can be reopened, e.g. after being moved by an external logrotate
+type FileLogger struct {
+	filename            string
+	file                io.Writer
+	mux                 sync.Mutex
+	reopenOnSIGHUP      bool
+	checkInterval       time.Duration
+	permissions         fileutil.Permissions
+	signals             chan os.Signal
+	closeSignalListener chan int
+	closedListener      chan int
+	watching            bool
+	closed              bool
+	closeOnce           sync.Once
+	closeDone           chan struct{}
+	closeErr            error
+	logs.SimpleLogger
+}
+
+func NewFileLogger(level logs.LoggerLevelMode, filename string, opts Options, fixedValues ...logs.FieldValue) (*FileLogger, error) {
+	if opts.CheckInterval < 0 {
+		return nil, ErrInvalidCheckInterval
+	}
+	fl := FileLogger{
+		filename:            filename,
+		reopenOnSIGHUP:      opts.ReopenOnSIGHUP,
+		checkInterval:       opts.CheckInterval,
+		permissions:         fileutil.Permissions{FileMode: opts.FileMode, DirMode: opts.DirMode, Owner: opts.Owner},
+		signals:             make(chan os.Signal, 1),
+		closeSignalListener: make(chan int),
+		closedListener:      make(chan int, 1),
+		SimpleLogger:        logs.SimpleLogger{FieldsValues: fixedValues[:], LevelSelected: level},
+	}
+	f, err := openFile(&fl)
+	if err != nil {
+		return nil, err
+	}
+	fl.file = f
+	return &fl, nil
+}
+
+func (fl *FileLogger) Init() {
+	fl.SimpleLogger.Init()
+	log.SetOutput(fl)
+	if fl.reopenOnSIGHUP || fl.checkInterval > 0 {
+		fl.watching = true
+		if fl.reopenOnSIGHUP {
+			signal.Notify(fl.signals, syscall.SIGHUP)
+		}
+		go watchFile(fl)
+	}
+}
+
+// Write returns ErrLoggerClosed after the logger is closed, the entry is dropped
+func (fl *FileLogger) Write(p []byte) (int, error) {
+	fl.mux.Lock()
+	if fl.closed {
+		fl.mux.Unlock()
+		return 0, ErrLoggerClosed
+	}
+	n, err := fl.file.Write(p)
+	fl.mux.Unlock()
+	fl.Counters().CountWrite(n, err)
+	return n, err
+}
+
+func (fl *FileLogger) SetWriter(writer io.Writer) {
+	log.SetOutput(fl)
+}
+
+// Reopen closes the current file and opens the filename again, creating it if it was moved or removed
+func (fl *FileLogger) Reopen() error {
+	f, err := openFile(fl)
+	if err != nil {
+		return err
+	}
+	fl.mux.Lock()
+	defer fl.mux.Unlock()
+	if fl.closed {
+		f.Close()
+		return nil
+	}
+	oldFile := fl.file.(*os.File)
+	fl.file = f
+	oldFile.Sync()
+	return oldFile.Close()
+}
+
+// Stats returns the entries logged per level, the writes and the size of the file
+func (fl *FileLogger) Stats() logs.Stats {
+	s := fl.SimpleLogger.Stats()
+	fl.mux.Lock()
+	if f, ok := fl.file.(*os.File); ok && !fl.closed {
+		if info, err := f.Stat(); err == nil {
+			s.CurrentFileSize = info.Size()
+		}
+	}
+	fl.mux.Unlock()
+	return s
+}
+
+// Close stops watching the file, then syncs and closes it. The entries logged after it are dropped
+func (fl *FileLogger) Close() {
+	fl.startClosing()
+	<-fl.closeDone
+}
+
+// Shutdown closes the logger as Close, waiting until the closing is done or ctx is done, when it returns ctx.Err()
+// and the closing goes on in background. It can be called many times, even concurrently, returning ErrLoggerClosed
+// when it is not the first call of Close or Shutdown
+func (fl *FileLogger) Shutdown(ctx context.Context) error {
+	first := fl.startClosing()
+	select {
+	case <-fl.closeDone:
+		if !first {
+			return ErrLoggerClosed
+		}
+		return fl.closeErr
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// startClosing closes the logger in background on the first call, returning whether it was the first one
+func (fl *FileLogger) startClosing() bool {
+	first := false
+	fl.closeOnce.Do(func() {
+		first = true
+		fl.closeDone = make(chan struct{})
+		go func() {
+			fl.closeErr = fl.close()
+			close(fl.closeDone)
+		}()
+	})
+	return first
+}
+
+func (fl *FileLogger) close() error {
+	if fl.watching {
+		signal.Stop(fl.signals)
+		fl.closeSignalListener <- 1
+		<-fl.closedListener
+	}
+	fl.mux.Lock()
+	defer fl.mux.Unlock()
+	fl.closed = true
+	f := fl.file.(*os.File)
+	err := f.Sync()
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// wasMoved reports whether the filename does not point to the file being written anymore
+func wasMoved(fl *FileLogger) bool {
+	fl.mux.Lock()
+	f := fl.file.(*os.File)
+	currentInfo, err := f.Stat()
+	fl.mux.Unlock()
+	if err != nil {
+		return false
+	}
+	info, err := os.Stat(fl.filename)
+	if err != nil {
+		return os.IsNotExist(err)
+	}
+	return !os.SameFile(info, currentInfo)
+}
+
+func watchFile(fl *FileLogger) {
+	var check <-chan time.Time
+	if fl.checkInterval > 0 {
+		tick := time.NewTicker(fl.checkInterval)
+		defer tick.Stop()
+		check = tick.C
+	}
+	for {
+		select {
+		case <-fl.signals:
+			fl.Debugf("Reopening log file %s after signal", fl.filename)
+			if err := fl.Reopen(); err != nil {
+				fl.Errorf("It was not possible reopen the file %s - Error: %s", fl.filename, err)
+			}
+		case <-check:
+			if wasMoved(fl) {
+				if err := fl.Reopen(); err != nil {
+					fl.Errorf("It was not possible reopen the file %s - Error: %s", fl.filename, err)
+				} else {
+					fl.Debugf("Log file %s reopened after being moved or removed", fl.filename)
+				}
+			}
+		case <-fl.closeSignalListener:
+			fl.closedListener <- 1
+			return
+		}
+	}
+}
+
+// openFile creates the missing directories of the filename
+func openFile(fl *FileLogger) (*os.File, error) {
+	return fl.permissions.OpenFile(fl.filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY)
+}
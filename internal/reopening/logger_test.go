@@ -0,0 +1,177 @@
+package reopening
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	logs "github.com/Murilovisque/logs/v3/internal"
+)
+
+func TestReopenAfterFileMoved(t *testing.T) {
+	dir, filename := setup(t)
+	defer os.RemoveAll(dir)
+	fl, err := NewFileLogger(logs.LogDebugMode, filename, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fl.Init()
+	defer fl.Close()
+	fl.Info("before")
+	movedFilename := filename + ".1"
+	if err := os.Rename(filename, movedFilename); err != nil {
+		t.Fatal(err)
+	}
+	fl.Info("moved")
+	if err := fl.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	fl.Info("after")
+	assertFileContent(t, movedFilename, "before", "moved")
+	assertFileContent(t, filename, "after")
+}
+
+func TestReopenOnSIGHUP(t *testing.T) {
+	dir, filename := setup(t)
+	defer os.RemoveAll(dir)
+	fl, err := NewFileLogger(logs.LogDebugMode, filename, Options{ReopenOnSIGHUP: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fl.Init()
+	defer fl.Close()
+	if err := os.Rename(filename, filename+".1"); err != nil {
+		t.Fatal(err)
+	}
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Signal(syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	waitReopened(t, fl)
+}
+
+func TestReopenWhenFileRemoved(t *testing.T) {
+	dir, filename := setup(t)
+	defer os.RemoveAll(dir)
+	fl, err := NewFileLogger(logs.LogInfoMode, filename, Options{CheckInterval: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fl.Init()
+	defer fl.Close()
+	if err := os.Remove(filename); err != nil {
+		t.Fatal(err)
+	}
+	waitReopened(t, fl)
+	fl.Info("after")
+	assertFileContent(t, filename, "after")
+}
+
+func TestNewFileLoggerWithPermissions(t *testing.T) {
+	dir, _ := setup(t)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "logs", "teste.log")
+	fl, err := NewFileLogger(logs.LogDebugMode, filename, Options{FileMode: 0600})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fl.Init()
+	fl.Close()
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode() != 0600 {
+		t.Fatalf("Expected mode %v, but received %v", os.FileMode(0600), info.Mode())
+	}
+}
+
+func TestNewFileLoggerWithInvalidCheckInterval(t *testing.T) {
+	if _, err := NewFileLogger(logs.LogDebugMode, "teste.log", Options{CheckInterval: -1}); err != ErrInvalidCheckInterval {
+		t.Fatalf("Expected %v, but received %v", ErrInvalidCheckInterval, err)
+	}
+}
+
+func TestFileLoggerStats(t *testing.T) {
+	dir, filename := setup(t)
+	defer os.RemoveAll(dir)
+	fl, err := NewFileLogger(logs.LogInfoMode, filename, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fl.Init()
+	defer fl.Close()
+	fl.Info("teste")
+	fl.Debug("dropped")
+	s := fl.Stats()
+	if s.Entries[logs.LogInfoMode] != 1 || s.Entries[logs.LogDebugMode] != 0 {
+		t.Fatalf("Unexpected entries %v", s.Entries)
+	}
+	if s.BytesWritten == 0 || s.CurrentFileSize != int64(s.BytesWritten) {
+		t.Fatalf("Unexpected stats %+v", s)
+	}
+}
+
+func TestFileLoggerCloseAndShutdownAreIdempotent(t *testing.T) {
+	dir, filename := setup(t)
+	defer os.RemoveAll(dir)
+	fl, err := NewFileLogger(logs.LogDebugMode, filename, Options{ReopenOnSIGHUP: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fl.Init()
+	fl.Info("before")
+	if err := fl.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	fl.Close()
+	if err := fl.Shutdown(context.Background()); err != ErrLoggerClosed {
+		t.Fatalf("Expected %v, but received %v", ErrLoggerClosed, err)
+	}
+	if _, err := fl.Write([]byte("after\n")); err != ErrLoggerClosed {
+		t.Fatalf("Expected %v, but received %v", ErrLoggerClosed, err)
+	}
+	assertFileContent(t, filename, "before")
+}
+
+func setup(t *testing.T) (string, string) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, filepath.Join(dir, "teste.log")
+}
+
+func waitReopened(t *testing.T, fl *FileLogger) {
+	for i := 0; i < 100; i++ {
+		if !wasMoved(fl) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("File %s was not reopened", fl.filename)
+}
+
+func assertFileContent(t *testing.T, filename string, messages ...string) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != len(messages) {
+		t.Fatalf("Expected %d lines in %s, but found %v", len(messages), filename, lines)
+	}
+	for i, m := range messages {
+		if !strings.HasSuffix(lines[i], m) {
+			t.Fatalf("Expected '%s', but '%s' was logged in %s", m, lines[i], filename)
+		}
+	}
+}
@@ -1,280 +1,175 @@
 package rotating
 
 import (
-	"errors"
-	"fmt"
+	"bytes"
+	"context"
 	"io"
 	"log"
-	"os"
-	"path"
-	"path/filepath"
-	"regexp"
-	"strings"
-	"sync"
 	"time"
 
 	logs "github.com/Murilovisque/logs/v3/internal"
-	"github.com/Murilovisque/logs/v3/internal/compressor"
+	"github.com/Murilovisque/logs/v3/internal/circular"
+	"github.com/Murilovisque/logs/v3/rotatingfile"
 )
 
-type TimeRotatingScheme string
+type (
+	TimeRotatingScheme = rotatingfile.TimeRotatingScheme
+	Options            = rotatingfile.Options
+)
 
 const (
-	PerDay  TimeRotatingScheme = "perDay"
-	PerHour TimeRotatingScheme = "perHour"
+	PerDay  = rotatingfile.PerDay
+	PerHour = rotatingfile.PerHour
 )
 
 var (
-	ErrInvalidAmountOfFilesToRetain = errors.New("amount of files to retain is less than zero")
+	ErrInvalidAmountOfFilesToRetain = rotatingfile.ErrInvalidAmountOfFilesToRetain
+	ErrLoggerClosed                 = rotatingfile.ErrClosed
 )
 
-func (trs TimeRotatingScheme) rotatingInterval() time.Duration {
-	switch trs {
-	case PerDay:
-		return time.Hour * 24
-	case PerHour:
-		return time.Hour
-	default:
-		panic("Not implemented")
-	}
+// TimeRotatingLogger logs to a rotatingfile.Writer
+type TimeRotatingLogger struct {
+	writer      *rotatingfile.Writer
+	syncOnError bool
+	logs.SimpleLogger
 }
 
-func (trs TimeRotatingScheme) nextTruncatedTimeAfter(t time.Time) time.Time {
-	switch trs {
-	case PerDay:
-		t = t.AddDate(0, 0, 1)
-		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
-	case PerHour:
-		t = t.Add(time.Hour)
-		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
-	default:
-		panic("Not implemented")
+func NewTimeRotatingLogger(level logs.LoggerLevelMode, filename string, rotatingScheme TimeRotatingScheme, amountOfFilesToRetain int, compressOldFiles bool, fixedValues ...logs.FieldValue) (*TimeRotatingLogger, error) {
+	opts := Options{RotatingScheme: rotatingScheme, AmountOfFilesToRetain: amountOfFilesToRetain}
+	if compressOldFiles {
+		opts.Compressor = rotatingfile.NewZipCompressor()
+	}
+	return NewTimeRotatingLoggerWithOptions(level, filename, opts, fixedValues...)
+}
+
+// NewLoggerWithOptions creates a CircularLogger when opts.CircularSize is set, a TimeRotatingLogger otherwise
+func NewLoggerWithOptions(level logs.LoggerLevelMode, filename string, opts Options, fixedValues ...logs.FieldValue) (logs.Logger, error) {
+	if opts.CircularSize != 0 {
+		l, err := circular.NewCircularLogger(level, filename, circular.Options{
+			Size:     opts.CircularSize,
+			FileMode: opts.FileMode,
+			DirMode:  opts.DirMode,
+			Owner:    opts.Owner,
+		}, fixedValues...)
+		if err != nil {
+			return nil, err
+		}
+		return l, nil
 	}
-}
-
-func (trs TimeRotatingScheme) nowTruncated() time.Time {
-	t := time.Now()
-	switch trs {
-	case PerDay:
-		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
-	case PerHour:
-		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
-	default:
-		panic("Not implemented")
+	l, err := NewTimeRotatingLoggerWithOptions(level, filename, opts, fixedValues...)
+	if err != nil {
+		return nil, err
 	}
+	return l, nil
 }
 
-func (trs TimeRotatingScheme) timeExtensionFormat() string {
-	switch trs {
-	case PerDay:
-		return "20060102"
-	case PerHour:
-		return "20060102-15"
-	default:
-		panic("Not implemented")
+func NewTimeRotatingLoggerWithOptions(level logs.LoggerLevelMode, filename string, opts Options, fixedValues ...logs.FieldValue) (*TimeRotatingLogger, error) {
+	t := TimeRotatingLogger{
+		SimpleLogger: logs.SimpleLogger{FieldsValues: fixedValues[:], LevelSelected: level},
+		syncOnError:  opts.SyncPolicy == rotatingfile.SyncOnError,
 	}
-}
-
-func (trs TimeRotatingScheme) timeExtensionRegex() string {
-	switch trs {
-	case PerDay:
-		return "\\d{8}"
-	case PerHour:
-		return "\\d{8}-\\d{2}"
-	default:
-		panic("Not implemented")
+	if opts.Logger == nil {
+		opts.Logger = &t
 	}
-}
-
-type TimeRotatingLogger struct {
-	rotatingScheme        TimeRotatingScheme
-	filename              string
-	currentLogFilename    string
-	file                  io.Writer
-	mux                   sync.Mutex
-	amountOfFilesToRetain int
-	compressOldFiles      bool
-	closeSignalListener   chan int
-	closedListener        chan int
-	closed                bool
-	logs.SimpleLogger
-}
-
-func NewTimeRotatingLogger(level logs.LoggerLevelMode, filename string, rotatingScheme TimeRotatingScheme, amountOfFilesToRetain int, compressOldFiles bool, fixedValues ...logs.FieldValue) (*TimeRotatingLogger, error) {
-	if amountOfFilesToRetain < 0 {
-		return nil, ErrInvalidAmountOfFilesToRetain
+	if opts.Header {
+		t.SimpleLogger.Init()
+		opts.HeaderFields = append([]rotatingfile.HeaderField{{Key: "level", Value: string(level)}}, opts.HeaderFields...)
+		if opts.HeaderFormatter == nil {
+			opts.HeaderFormatter = t.formatHeader
+		}
 	}
-	newFilename := buildFilenameWithTimeExtension(time.Now(), filename, rotatingScheme)
-	f, err := os.OpenFile(newFilename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	w, err := rotatingfile.Open(filename, opts)
 	if err != nil {
 		return nil, err
 	}
-	t := TimeRotatingLogger{
-		rotatingScheme:        rotatingScheme,
-		filename:              filename,
-		currentLogFilename:    newFilename,
-		file:                  f,
-		closeSignalListener:   make(chan int),
-		closedListener:        make(chan int, 1),
-		amountOfFilesToRetain: amountOfFilesToRetain,
-		compressOldFiles:      compressOldFiles,
-		SimpleLogger:          logs.SimpleLogger{FieldsValues: fixedValues[:], LevelSelected: level},
-	}
+	t.writer = w
 	return &t, nil
 }
 
+// formatHeader writes the header fields as INFO entries, in the same format of the other entries
+func (trl *TimeRotatingLogger) formatHeader(fields []rotatingfile.HeaderField) []byte {
+	var buf bytes.Buffer
+	l := log.New(&buf, log.Prefix(), log.Flags())
+	for _, f := range fields {
+		l.Println(trl.Message(logs.LogInfoMode, f.Key+": "+f.Value))
+	}
+	return buf.Bytes()
+}
+
 func (trl *TimeRotatingLogger) Init() {
 	trl.SimpleLogger.Init()
 	log.SetOutput(trl)
-	go rotatingFile(trl)
+	trl.writer.Start()
 }
 
+// Write writes the entries of every logger, as all of them write through the log package. While the disk is low,
+// the entries below WARN and the lines without level are dropped. The FATAL entries are synced before the exit, the
+// ERROR ones too with the SyncOnError policy. It returns ErrLoggerClosed after the logger is closed
 func (trl *TimeRotatingLogger) Write(p []byte) (int, error) {
-	trl.mux.Lock()
-	n, err := trl.file.Write(p)
-	trl.mux.Unlock()
-	return n, err
-}
-
-func (trl *TimeRotatingLogger) SetWriter(writer io.Writer) {
-	log.SetOutput(trl)
-}
-
-func (trl *TimeRotatingLogger) Close() {
-	if !trl.closed {
-		trl.closed = true
-		trl.closeSignalListener <- 1
-		moment := trl.rotatingScheme.nowTruncated()
-		removeOldFiles(moment, trl)
-		trl.mux.Lock()
-		trl.file.(*os.File).Sync()
-		trl.file.(*os.File).Close()
-		trl.file = os.Stderr
-		trl.mux.Unlock()
+	level, ok := logs.EntryLevel(p)
+	if !trl.diskOK() && (!ok || level == logs.LogInfoMode || level == logs.LogDebugMode) {
+		return len(p), nil
+	}
+	n, err := trl.writer.Write(p)
+	if err == nil && (level == logs.LogFatalMode || level == logs.LogErrorMode && trl.syncOnError) {
+		trl.writer.Sync()
 	}
-	<-trl.closedListener
+	return n, err
 }
 
-func durationUntilNextRotating(moment time.Time, rotatingScheme TimeRotatingScheme) time.Duration {
-	nextRotatingTime := rotatingScheme.nextTruncatedTimeAfter(moment)
-	nextDuration := nextRotatingTime.Sub(moment)
-	if nextDuration < 1 {
-		return 1
-	}
-	return nextDuration
+// diskOK is true while the writer is being opened, when it also logs through trl
+func (trl *TimeRotatingLogger) diskOK() bool {
+	return trl.writer == nil || trl.writer.DiskState() == rotatingfile.DiskOK
 }
 
-func buildFilenameWithTimeExtension(moment time.Time, filename string, rotatingScheme TimeRotatingScheme) string {
-	filenameExt := getFilenameExt(filename, true)
-	filenameWithoutExt := filename[:len(filename)-len(filenameExt)]
-	return fmt.Sprintf("%s-%s%s", filenameWithoutExt, moment.Format(rotatingScheme.timeExtensionFormat()), filenameExt)
+func (trl *TimeRotatingLogger) SetWriter(writer io.Writer) {
+	log.SetOutput(trl)
 }
 
-func lastFileTimeToRetain(moment time.Time, trl *TimeRotatingLogger) time.Time {
-	return moment.Add(trl.rotatingScheme.rotatingInterval() * time.Duration(trl.amountOfFilesToRetain) * -1)
+// Rotate rotates to a new file immediately, compressing and removing the old files as the scheduled rotation does
+func (trl *TimeRotatingLogger) Rotate() error {
+	return trl.writer.Rotate()
 }
 
-func mustFileBeRemoved(lastFileTime time.Time, filenameToCheck string, trl *TimeRotatingLogger) bool {
-	filenameEscaped := regexp.QuoteMeta(trl.filename)
-	filenameExt := getFilenameExt(filenameEscaped, false)
-	filenameWithoutExt := getFilenameWithoutExt(filenameEscaped)
-	regexPattern := fmt.Sprintf("^%s-(%s)%s(%s)?$", filenameWithoutExt, trl.rotatingScheme.timeExtensionRegex(), filenameExt, compressor.ZipExtensionRegex)
-	regex, err := regexp.Compile(regexPattern)
-	if err != nil {
-		trl.Errorf("Error to generate the regex pattern to remove old files %v", err)
-		return false
-	}
-	matchGroups := regex.FindStringSubmatch(filenameToCheck)
-	if len(matchGroups) < 2 {
-		return false
-	}
-	fileTime, err := time.ParseInLocation(trl.rotatingScheme.timeExtensionFormat(), matchGroups[1], lastFileTime.Location())
-	if err != nil {
-		return false
-	}
-	return fileTime.Before(lastFileTime)
+// Hold places a legal hold on the files of the periods overlapping [from, to), they are never removed by the retention
+func (trl *TimeRotatingLogger) Hold(from, to time.Time) error {
+	return trl.writer.Hold(from, to)
 }
 
-func getFilenameWithoutExt(filename string) string {
-	filenameExt := getFilenameExt(filename, true)
-	return filename[:len(filename)-len(filenameExt)]
+// Release removes the legal hold placed with the same interval
+func (trl *TimeRotatingLogger) Release(from, to time.Time) error {
+	return trl.writer.Release(from, to)
 }
 
-func getFilenameGlobWithoutExt(filename string) string {
-	filenameExt := getFilenameExt(filename, true)
-	return filename[:len(filename)-len(filenameExt)] + "*"
+// HeldFiles returns the files older than the retention kept only because of a legal hold, as found by the last
+// retention pass
+func (trl *TimeRotatingLogger) HeldFiles() []string {
+	return trl.writer.HeldFiles()
 }
 
-func getFilenameExt(filename string, includeCompressExtension bool) string {
-	filenameSize := len(filename)
-	if strings.HasSuffix(filename, compressor.ZipExtension) {
-		ext := path.Ext(filename[:filenameSize-len(compressor.ZipExtension)])
-		if includeCompressExtension {
-			return ext + compressor.ZipExtension
-		}
-		return ext
-	}
-	return path.Ext(filename)
+// Stats returns the entries logged per level and the stats of the rotating file
+func (trl *TimeRotatingLogger) Stats() logs.Stats {
+	s := trl.SimpleLogger.Stats()
+	ws := trl.writer.Stats()
+	s.BytesWritten = ws.BytesWritten
+	s.WriteErrors = ws.WriteErrors
+	s.Rotations = ws.Rotations
+	s.FilesRemoved = ws.FilesRemoved
+	s.FilesCompressed = ws.FilesCompressed
+	s.CompressionDuration = ws.CompressionDuration
+	s.UncompressedBytes = ws.UncompressedBytes
+	s.CompressedBytes = ws.CompressedBytes
+	s.CurrentFileSize = ws.CurrentFileSize
+	s.HeldFiles = ws.HeldFiles
+	return s
 }
 
-func removeOldFiles(moment time.Time, trl *TimeRotatingLogger) {
-	filenameWithoutExtGlob := getFilenameGlobWithoutExt(trl.filename)
-	fileEntries, err := filepath.Glob(filenameWithoutExtGlob)
-	if err != nil {
-		trl.Errorf("Glob %s failed. Is was not possible to remove old files - Error: %s", filenameWithoutExtGlob, err)
-	} else {
-		lastFileTime := lastFileTimeToRetain(moment, trl)
-		trl.Debugf("Last file moment to retain %v", lastFileTime)
-		for _, filename := range fileEntries {
-			if mustFileBeRemoved(lastFileTime, filename, trl) {
-				err := os.Remove(filename)
-				if err != nil {
-					trl.Errorf("Is was not possible to remove the old file %s - Error: %s", filename, err)
-				}
-			}
-		}
-	}
+// Shutdown flushes, syncs and closes the file, stopping the rotation, waiting until ctx is done. It can be called
+// many times, returning ErrLoggerClosed when it is not the first call. The entries logged after it are dropped
+func (trl *TimeRotatingLogger) Shutdown(ctx context.Context) error {
+	return trl.writer.Shutdown(ctx)
 }
 
-func rotatingFile(trl *TimeRotatingLogger) {
-	trl.Infof("Starting the log rotation: %v scheme", trl.rotatingScheme)
-	next := durationUntilNextRotating(time.Now(), trl.rotatingScheme)
-	trl.Debugf("Next log rotation will be at %v", next)
-	tick := time.NewTicker(next)
-	for {
-		select {
-		case <-tick.C:
-			moment := trl.rotatingScheme.nowTruncated()
-			trl.Debugf("Starting log rotating operation %v", moment)
-			newFilename := buildFilenameWithTimeExtension(moment, trl.filename, trl.rotatingScheme)
-			f, err := os.OpenFile(newFilename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-			if err != nil {
-				trl.Errorf("It was not possible rotate to file %s - Error: %s", newFilename, err)
-			} else {
-				oldLogFilename := trl.currentLogFilename
-				trl.mux.Lock()
-				trl.file.(*os.File).Sync()
-				trl.file.(*os.File).Close()
-				trl.currentLogFilename = newFilename
-				trl.file = f
-				trl.mux.Unlock()
-				if trl.compressOldFiles {
-					err := compressor.CompressFile(oldLogFilename)
-					if err != nil {
-						trl.Errorf("It was not possible compress the file %s - Error: %s", oldLogFilename, err)
-					} else {
-						os.Remove(oldLogFilename)
-					}
-				}
-				trl.Debugf("Log rotated to new file: %s", newFilename)
-			}
-			removeOldFiles(moment, trl)
-			next = durationUntilNextRotating(time.Now(), trl.rotatingScheme)
-			tick.Reset(next)
-			trl.Debugf("Log rotating operation finished, next will be at %v", next)
-		case <-trl.closeSignalListener:
-			trl.closedListener <- 1
-			return
-		}
-	}
+func (trl *TimeRotatingLogger) Close() {
+	trl.writer.Close()
 }
@@ -1,232 +1,345 @@
 package rotating
 
 import (
+	"context"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/Murilovisque/logs/v3/circularfile"
+	logs "github.com/Murilovisque/logs/v3/internal"
+	"github.com/Murilovisque/logs/v3/internal/circular"
+	"github.com/Murilovisque/logs/v3/rotatingfile"
+	"github.com/Murilovisque/logs/v3/rotatingfile/rotatingtest"
 )
 
-func TestDurationUntilNextRotating(t *testing.T) {
-	now, _ := time.Parse("2006 Jan 02 15:04:05", "2012 Dec 07 12:15:30")
-	expectedPerDay := 11*time.Hour + 44*time.Minute + 30*time.Second
-	vl := durationUntilNextRotating(now, PerDay)
-	if vl != expectedPerDay {
-		t.Fatal(vl)
-	}
-	expectedPerHour := 44*time.Minute + 30*time.Second
-	vl = durationUntilNextRotating(now, PerHour)
-	if vl != expectedPerHour {
-		t.Fatal(vl)
+func TestTimeRotatingLoggerRotate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	trl, err := NewTimeRotatingLoggerWithOptions(logs.LogInfoMode, filepath.Join(dir, "teste.log"), Options{RotatingScheme: PerDay, AmountOfFilesToRetain: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trl.Init()
+	trl.Info("first")
+	if err := trl.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	trl.Info("second")
+	trl.Close()
+	if err := trl.Rotate(); err != ErrLoggerClosed {
+		t.Fatalf("Expected %v, but received %v", ErrLoggerClosed, err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "teste-*.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("Expected 2 files, but found %v", files)
+	}
+	// teste-<date>.1.log is sorted before teste-<date>.log
+	for i, m := range []string{"second", "first"} {
+		content, err := ioutil.ReadFile(files[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(content), "INFO * "+m+"\n") {
+			t.Fatalf("Expected '%s' in %s, but found '%s'", m, files[i], content)
+		}
 	}
 }
 
-func TestBuildFilenameWithTimeExtension(t *testing.T) {
-	now, _ := time.Parse("2006 Jan 02 15:04:05", "2012 Dec 07 06:15:30")
-	tests := []struct {
-		vl             string
-		exp            string
-		rotatingScheme TimeRotatingScheme
-	}{
-		{"/varl/log/teste.log", "/varl/log/teste-20121207.log", PerDay},
-		{"/varl/log/teste", "/varl/log/teste-20121207", PerDay},
-		{"/varl/log/", "/varl/log/-20121207", PerDay},
-		{"/varl/log/teste.log", "/varl/log/teste-20121207-06.log", PerHour},
-		{"/varl/log/teste", "/varl/log/teste-20121207-06", PerHour},
-		{"/varl/log/", "/varl/log/-20121207-06", PerHour},
-	}
-	for _, test := range tests {
-		f := buildFilenameWithTimeExtension(now, test.vl, test.rotatingScheme)
-		if f != test.exp {
-			t.Fatal(f)
+func TestTimeRotatingLoggerWithFakeClock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	clock := rotatingtest.NewFakeClock(time.Date(2026, 10, 17, 12, 0, 0, 0, time.Local))
+	trl, err := NewTimeRotatingLoggerWithOptions(logs.LogInfoMode, filepath.Join(dir, "teste.log"), Options{
+		RotatingScheme:        PerDay,
+		AmountOfFilesToRetain: 1,
+		Compressor:            rotatingfile.NewZipCompressor(),
+		Clock:                 clock,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trl.Init()
+	defer trl.Close()
+	for _, m := range []string{"day 17", "day 18", "day 19"} {
+		trl.Info(m)
+		clock.Advance(24 * time.Hour)
+		trl.writer.WaitIdle()
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "teste-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"teste-20261019.log.zip", "teste-20261020.log"}
+	if len(files) != len(expected) {
+		t.Fatalf("Expected %v, but found %v", expected, files)
+	}
+	for i := range files {
+		if filepath.Base(files[i]) != expected[i] {
+			t.Fatalf("Expected %v, but found %v", expected, files)
 		}
 	}
 }
 
-func TestMustFileBeRemoved(t *testing.T) {
-	lastFileTimePerDay, _ := time.Parse("2006 Jan 02", "2012 Dec 07")
-	lastFileTimePerHour, _ := time.Parse("2006 Jan 02 15", "2012 Dec 07 06")
-	tests := []struct {
-		vl           string
-		exp          bool
-		trl          *TimeRotatingLogger
-		lastFileTime time.Time
-		so           string
-	}{
-		{"/varl/log/teste.log", false, &TimeRotatingLogger{filename: "/varl/log/teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "linux"},
-		{"/varl/log/teste", false, &TimeRotatingLogger{filename: "/varl/log/teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "linux"},
-		{"C:\\Test.Legal\\Temp\\teste", false, &TimeRotatingLogger{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "windows"},
-		{"/varl/log/", false, &TimeRotatingLogger{filename: "/varl/log/teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "linux"},
-		{"C:\\Test.Legal\\Temp\\", false, &TimeRotatingLogger{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "linux"},
-		{"/varl/log/teste.log", false, &TimeRotatingLogger{filename: "/varl/log/teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
-		{"/varl/log/teste", false, &TimeRotatingLogger{filename: "/varl/log/teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
-		{"C:\\Test.Legal\\Temp\\teste", false, &TimeRotatingLogger{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "windows"},
-		{"/varl/log/", false, &TimeRotatingLogger{filename: "/varl/log/teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
-		{"C:\\Test.Legal\\Temp\\", false, &TimeRotatingLogger{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
-		{"/varl/log/teste-20121207.log", false, &TimeRotatingLogger{filename: "/varl/log/teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "linux"},
-		{"/varl/log/teste-20121208.log", false, &TimeRotatingLogger{filename: "/varl/log/teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "linux"},
-		{"C:\\Test.Legal\\Temp\\teste-20121207.log", false, &TimeRotatingLogger{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "windows"},
-		{"C:\\Test.Legal\\Temp\\teste-20121208.log", false, &TimeRotatingLogger{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "windows"},
-
-		{"/varl/log/teste.log.zip", false, &TimeRotatingLogger{filename: "/varl/log/teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "linux"},
-		{"/varl/log/teste.zip", false, &TimeRotatingLogger{filename: "/varl/log/teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "linux"},
-		{"C:\\Test.Legal\\Temp\\teste.zip", false, &TimeRotatingLogger{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "windows"},
-		{"/varl/log/teste.log.zip", false, &TimeRotatingLogger{filename: "/varl/log/teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
-		{"/varl/log/teste.zip", false, &TimeRotatingLogger{filename: "/varl/log/teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
-		{"C:\\Test.Legal\\Temp\\teste.zip", false, &TimeRotatingLogger{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "windows"},
-		{"/varl/log/", false, &TimeRotatingLogger{filename: "/varl/log/teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
-		{"C:\\Test.Legal\\Temp\\", false, &TimeRotatingLogger{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
-		{"/varl/log/teste-20121207.log.zip", false, &TimeRotatingLogger{filename: "/varl/log/teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "linux"},
-		{"/varl/log/teste-20121208.log.zip", false, &TimeRotatingLogger{filename: "/varl/log/teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "linux"},
-		{"C:\\Test.Legal\\Temp\\teste-20121207.log.zip", false, &TimeRotatingLogger{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "windows"},
-		{"C:\\Test.Legal\\Temp\\teste-20121208.log.zip", false, &TimeRotatingLogger{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "windows"},
-
-		{"/varl/log/teste-20121207-06.log", false, &TimeRotatingLogger{filename: "/varl/log/teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
-		{"/varl/log/teste-20121207-07.log", false, &TimeRotatingLogger{filename: "/varl/log/teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
-		{"C:\\Test.Legal\\Temp\\teste-20121207-06.log", false, &TimeRotatingLogger{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
-		{"C:\\Test.Legal\\Temp\\teste-20121207-07.log", false, &TimeRotatingLogger{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
-		{"/varl/log/teste-20121206.log", true, &TimeRotatingLogger{filename: "/varl/log/teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "linux"},
-		{"C:\\Test.Legal\\Temp\\teste-20121206.log", true, &TimeRotatingLogger{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "windows"},
-		{"/varl/log/teste-20121207-05.log", true, &TimeRotatingLogger{filename: "/varl/log/teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
-		{"/varl/log/teste-20121206-07.log", true, &TimeRotatingLogger{filename: "/varl/log/teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
-		{"C:\\Test.Legal\\Temp\\teste-20121206-05.log", true, &TimeRotatingLogger{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
-		{"C:\\Test.Legal\\Temp\\teste-20121206-06.log", true, &TimeRotatingLogger{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
-		{"C:\\Test.Legal\\Temp\\teste-20121206-07.log", true, &TimeRotatingLogger{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
-
-		{"/varl/log/teste-20121207-06.log.zip", false, &TimeRotatingLogger{filename: "/varl/log/teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
-		{"/varl/log/teste-20121207-07.log.zip", false, &TimeRotatingLogger{filename: "/varl/log/teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
-		{"C:\\Test.Legal\\Temp\\teste-20121207-06.log.zip", false, &TimeRotatingLogger{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
-		{"C:\\Test.Legal\\Temp\\teste-20121207-07.log.zip", false, &TimeRotatingLogger{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
-		{"/varl/log/teste-20121206.log.zip", true, &TimeRotatingLogger{filename: "/varl/log/teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "linux"},
-		{"C:\\Test.Legal\\Temp\\teste-20121206.log.zip", true, &TimeRotatingLogger{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "windows"},
-		{"/varl/log/teste-20121207-05.log.zip", true, &TimeRotatingLogger{filename: "/varl/log/teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
-		{"/varl/log/teste-20121206-07.log.zip", true, &TimeRotatingLogger{filename: "/varl/log/teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
-		{"C:\\Test.Legal\\Temp\\teste-20121206-05.log.zip", true, &TimeRotatingLogger{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
-		{"C:\\Test.Legal\\Temp\\teste-20121206-06.log.zip", true, &TimeRotatingLogger{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
-		{"C:\\Test.Legal\\Temp\\teste-20121206-07.log.zip", true, &TimeRotatingLogger{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
+func TestNewTimeRotatingLoggerWithInvalidAmountOfFilesToRetain(t *testing.T) {
+	_, err := NewTimeRotatingLogger(logs.LogInfoMode, "teste.log", PerDay, -1, false)
+	if err != ErrInvalidAmountOfFilesToRetain {
+		t.Fatalf("Expected %v, but received %v", ErrInvalidAmountOfFilesToRetain, err)
+	}
+}
 
-		{"/varl/log/teste-20121207-06", false, &TimeRotatingLogger{filename: "/varl/log/teste", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
-		{"/varl/log/teste-20121207-07", false, &TimeRotatingLogger{filename: "/varl/log/teste", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
-		{"C:\\Test.Legal\\Temp\\teste-20121207-06", false, &TimeRotatingLogger{filename: "C:\\Test.Legal\\Temp\\teste", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
-		{"C:\\Test.Legal\\Temp\\teste-20121207-07", false, &TimeRotatingLogger{filename: "C:\\Test.Legal\\Temp\\teste", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
-		{"/varl/log/teste-20121206", true, &TimeRotatingLogger{filename: "/varl/log/teste", rotatingScheme: PerDay}, lastFileTimePerDay, "linux"},
-		{"C:\\Test.Legal\\Temp\\teste-20121206", true, &TimeRotatingLogger{filename: "C:\\Test.Legal\\Temp\\teste", rotatingScheme: PerDay}, lastFileTimePerDay, "windows"},
-		{"/varl/log/teste-20121207-05", true, &TimeRotatingLogger{filename: "/varl/log/teste", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
-		{"/varl/log/teste-20121206-07", true, &TimeRotatingLogger{filename: "/varl/log/teste", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
-		{"C:\\Test.Legal\\Temp\\teste-20121206-05", true, &TimeRotatingLogger{filename: "C:\\Test.Legal\\Temp\\teste", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
-		{"C:\\Test.Legal\\Temp\\teste-20121206-06", true, &TimeRotatingLogger{filename: "C:\\Test.Legal\\Temp\\teste", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
-		{"C:\\Test.Legal\\Temp\\teste-20121206-07", true, &TimeRotatingLogger{filename: "C:\\Test.Legal\\Temp\\teste", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
+func TestNewLoggerWithOptionsWithCircularSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "teste.log")
+	l, err := NewLoggerWithOptions(logs.LogInfoMode, filename, Options{RotatingScheme: PerDay, CircularSize: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := l.(*circular.CircularLogger); !ok {
+		t.Fatalf("Expected a circular logger, but received %T", l)
+	}
+	l.Init()
+	l.Info("teste")
+	l.Close()
+	r, err := circularfile.NewReader(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	entry, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(entry), "INFO * teste\n") {
+		t.Fatalf("Unexpected entry '%s'", entry)
+	}
+	if _, err := rotatingfile.Open(filename, Options{RotatingScheme: PerDay, CircularSize: 1024}); err != rotatingfile.ErrCircularNotSupported {
+		t.Fatalf("Expected %v, but received %v", rotatingfile.ErrCircularNotSupported, err)
+	}
+}
 
-		{"/varl/log/teste-20121207-06.zip", false, &TimeRotatingLogger{filename: "/varl/log/teste", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
-		{"/varl/log/teste-20121207-07.zip", false, &TimeRotatingLogger{filename: "/varl/log/teste", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
-		{"C:\\Test.Legal\\Temp\\teste-20121207-06.zip", false, &TimeRotatingLogger{filename: "C:\\Test.Legal\\Temp\\teste", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
-		{"C:\\Test.Legal\\Temp\\teste-20121207-07.zip", false, &TimeRotatingLogger{filename: "C:\\Test.Legal\\Temp\\teste", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
-		{"/varl/log/teste-20121206.zip", true, &TimeRotatingLogger{filename: "/varl/log/teste", rotatingScheme: PerDay}, lastFileTimePerDay, "linux"},
-		{"C:\\Test.Legal\\Temp\\teste-20121206.zip", true, &TimeRotatingLogger{filename: "C:\\Test.Legal\\Temp\\teste", rotatingScheme: PerDay}, lastFileTimePerDay, "windows"},
-		{"/varl/log/teste-20121207-05.zip", true, &TimeRotatingLogger{filename: "/varl/log/teste", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
-		{"/varl/log/teste-20121206-07.zip", true, &TimeRotatingLogger{filename: "/varl/log/teste", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
-		{"C:\\Test.Legal\\Temp\\teste-20121206-05.zip", true, &TimeRotatingLogger{filename: "C:\\Test.Legal\\Temp\\teste", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
-		{"C:\\Test.Legal\\Temp\\teste-20121206-06.zip", true, &TimeRotatingLogger{filename: "C:\\Test.Legal\\Temp\\teste", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
-		{"C:\\Test.Legal\\Temp\\teste-20121206-07.zip", true, &TimeRotatingLogger{filename: "C:\\Test.Legal\\Temp\\teste", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
-	}
-	os := runtime.GOOS
-	for i, test := range tests {
-		if !strings.HasPrefix(test.so, os) {
-			continue
-		}
-		must := mustFileBeRemoved(test.lastFileTime, test.vl, test.trl)
-		if must != test.exp {
-			t.Fatal(i, test)
+func TestTimeRotatingLoggerHeader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	trl, err := NewTimeRotatingLoggerWithOptions(logs.LogWarnMode, filepath.Join(dir, "teste.log"), Options{RotatingScheme: PerDay, Header: true}, logs.FieldValue{Key: "app", Val: "teste"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := trl.writer.Filename()
+	trl.Close()
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range []string{"INFO [app: teste] * level: WARN\n", "INFO [app: teste] * scheme: perDay\n"} {
+		if !strings.Contains(string(content), e) {
+			t.Fatalf("Expected '%s' in the header, but found '%s'", e, content)
 		}
 	}
 }
 
-func TestLastFileTimeToRetain(t *testing.T) {
-	lastFileTimePerDay, _ := time.Parse("2006 Jan 02", "2012 Dec 07")
-	lastFileTimePerHour, _ := time.Parse("2006 Jan 02 15", "2012 Dec 07 06")
-	tests := []struct {
-		vl  time.Time
-		exp time.Time
-		trl *TimeRotatingLogger
-	}{
-		{lastFileTimePerDay, time.Date(2012, 12, 7, 0, 0, 0, 0, time.Now().Location()), &TimeRotatingLogger{rotatingScheme: PerDay, amountOfFilesToRetain: 0}},
-		{lastFileTimePerDay, time.Date(2012, 12, 6, 0, 0, 0, 0, time.Now().Location()), &TimeRotatingLogger{rotatingScheme: PerDay, amountOfFilesToRetain: 1}},
-		{lastFileTimePerDay, time.Date(2012, 11, 27, 0, 0, 0, 0, time.Now().Location()), &TimeRotatingLogger{rotatingScheme: PerDay, amountOfFilesToRetain: 10}},
-		{lastFileTimePerHour, time.Date(2012, 12, 7, 6, 0, 0, 0, time.Now().Location()), &TimeRotatingLogger{rotatingScheme: PerHour, amountOfFilesToRetain: 0}},
-		{lastFileTimePerHour, time.Date(2012, 12, 7, 5, 0, 0, 0, time.Now().Location()), &TimeRotatingLogger{rotatingScheme: PerHour, amountOfFilesToRetain: 1}},
-		{lastFileTimePerHour, time.Date(2012, 12, 7, 0, 0, 0, 0, time.Now().Location()), &TimeRotatingLogger{rotatingScheme: PerHour, amountOfFilesToRetain: 6}},
-		{lastFileTimePerHour, time.Date(2012, 11, 6, 22, 0, 0, 0, time.Now().Location()), &TimeRotatingLogger{rotatingScheme: PerHour, amountOfFilesToRetain: 8}},
-	}
-	for _, test := range tests {
-		last := lastFileTimeToRetain(test.vl, test.trl)
-		if last.Equal(test.exp) {
-			t.Fatal(last)
-		}
+func TestTimeRotatingLoggerSyncOnError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	trl, err := NewTimeRotatingLoggerWithOptions(logs.LogInfoMode, filepath.Join(dir, "teste.log"), Options{
+		RotatingScheme: PerDay,
+		BufferSize:     4096,
+		FlushInterval:  time.Hour,
+		SyncPolicy:     rotatingfile.SyncOnError,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trl.Init()
+	defer trl.Close()
+	trl.Info("buffered")
+	content, err := ioutil.ReadFile(trl.writer.Filename())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(content) > 0 {
+		t.Fatalf("Expected the entry still buffered, but found '%s'", content)
+	}
+	trl.Errorf("failed %d", 1)
+	content, err = ioutil.ReadFile(trl.writer.Filename())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "INFO * buffered\n") || !strings.Contains(string(content), "ERROR * failed 1\n") {
+		t.Fatalf("Expected both entries synced, but found '%s'", content)
+	}
+	child := logs.SimpleLogger{LevelSelected: logs.LogInfoMode}
+	child.Init()
+	child.Error("child failed")
+	content, err = ioutil.ReadFile(trl.writer.Filename())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "ERROR * child failed\n") {
+		t.Fatalf("Expected the entry of the child logger synced, but found '%s'", content)
 	}
 }
 
-func TestGetFilenameGlobWithoutExt(t *testing.T) {
-	tests := []struct {
-		vl       string
-		expected string
-	}{
-		{"/var/log/teste.log", "/var/log/teste*"},
-		{"/var/log/teste-.log", "/var/log/teste-*"},
-		{"/var/log/teste-23.log", "/var/log/teste-23*"},
-		{"/var/log/teste.log.zip", "/var/log/teste*"},
-		{"teste.log", "teste*"},
-		{"/var/log/teste", "/var/log/teste*"},
-		{"teste", "teste*"},
-		{"teste.log.zip", "teste*"},
-	}
-	for _, test := range tests {
-		vl := getFilenameGlobWithoutExt(test.vl)
-		if vl != test.expected {
-			t.Fatalf("Expected %s, but received %s", test.expected, vl)
-		}
+func TestTimeRotatingLoggerSyncsFatalEntries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	trl, err := NewTimeRotatingLoggerWithOptions(logs.LogInfoMode, filepath.Join(dir, "teste.log"), Options{
+		RotatingScheme: PerDay,
+		BufferSize:     4096,
+		FlushInterval:  time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trl.Init()
+	defer trl.Close()
+	child := logs.SimpleLogger{LevelSelected: logs.LogInfoMode}
+	child.Init()
+	child.Error("buffered")
+	// the line written by Fatal before exiting, without exiting the test
+	log.Println(child.Message(logs.LogFatalMode, "exiting"))
+	content, err := ioutil.ReadFile(trl.writer.Filename())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "ERROR * buffered\n") || !strings.Contains(string(content), "FATAL * exiting\n") {
+		t.Fatalf("Expected both entries synced, but found '%s'", content)
 	}
 }
 
-func TestGetFilenameExt(t *testing.T) {
-	tests := []struct {
-		vl       string
-		expected string
-	}{
-		{"/var/log/teste.log", ".log"},
-		{"/var/log/teste-.log", ".log"},
-		{"/var/log/teste-23.log", ".log"},
-		{"teste.log", ".log"},
-		{"/var/log/teste", ""},
-		{"teste", ""},
-		{"teste.log.zip", ".log.zip"},
-		{"/var/log/teste.log.zip", ".log.zip"},
-	}
-	for _, test := range tests {
-		vl := getFilenameExt(test.vl, true)
-		if vl != test.expected {
-			t.Fatalf("Expected %s, but received %s", test.expected, vl)
-		}
+func TestTimeRotatingLoggerDropsEntriesBelowWarnWhenDiskIsLow(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("free disk space is checked with statfs")
+	}
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	trl, err := NewTimeRotatingLoggerWithOptions(logs.LogDebugMode, filepath.Join(dir, "teste.log"), Options{
+		RotatingScheme: PerDay,
+		DiskGuard:      &rotatingfile.DiskGuardOptions{DropBelow: math.MaxUint64},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trl.Init()
+	child := logs.SimpleLogger{FieldsValues: []logs.FieldValue{{Key: "reqid", Val: 1}}, LevelSelected: logs.LogDebugMode}
+	child.Init()
+	trl.Debug("dropped")
+	trl.Infof("%s", "dropped")
+	child.Info("dropped")
+	log.Print("dropped")
+	trl.Warn("kept")
+	child.Error("kept")
+	filename := trl.writer.Filename()
+	trl.Close()
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "dropped") || !strings.Contains(string(content), "WARN * kept\n") ||
+		!strings.Contains(string(content), "ERROR [reqid: 1] * kept\n") {
+		t.Fatalf("Expected only the WARN and ERROR entries, but found '%s'", content)
 	}
 }
 
-func TestGetFilenameWithoutExt(t *testing.T) {
-	tests := []struct {
-		vl       string
-		expected string
-	}{
-		{"/var/log/teste.log", "/var/log/teste"},
-		{"/var/log/teste-.log", "/var/log/teste-"},
-		{"/var/log/teste-23.log", "/var/log/teste-23"},
-		{"/var/log/teste.log.zip", "/var/log/teste"},
-		{"teste.log", "teste"},
-		{"/var/log/teste", "/var/log/teste"},
-		{"teste", "teste"},
-		{"teste.log.zip", "teste"},
-	}
-	for _, test := range tests {
-		vl := getFilenameWithoutExt(test.vl)
-		if vl != test.expected {
-			t.Fatalf("Expected %s, but received %s", test.expected, vl)
-		}
+func TestTimeRotatingLoggerStats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	heldFile := filepath.Join(dir, "teste-20121206.log")
+	if err := ioutil.WriteFile(heldFile, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	trl, err := NewTimeRotatingLoggerWithOptions(logs.LogInfoMode, filepath.Join(dir, "teste.log"), Options{RotatingScheme: PerDay, AmountOfFilesToRetain: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trl.Init()
+	defer trl.Close()
+	held := time.Date(2012, 12, 6, 0, 0, 0, 0, time.Local)
+	if err := trl.Hold(held, held.AddDate(0, 0, 1)); err != nil {
+		t.Fatal(err)
+	}
+	trl.Info("first")
+	trl.Error("second")
+	if err := trl.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	trl.writer.WaitIdle()
+	s := trl.Stats()
+	if s.Entries[logs.LogInfoMode] == 0 || s.Entries[logs.LogErrorMode] != 1 || s.BytesWritten == 0 || s.Rotations != 1 {
+		t.Fatalf("Unexpected stats %+v", s)
+	}
+	if len(s.HeldFiles) != 1 || s.HeldFiles[0] != heldFile {
+		t.Fatalf("Expected %s held, but received %v", heldFile, s.HeldFiles)
+	}
+}
+
+func TestTimeRotatingLoggerShutdown(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	trl, err := NewTimeRotatingLoggerWithOptions(logs.LogInfoMode, filepath.Join(dir, "teste.log"), Options{
+		RotatingScheme: PerDay,
+		BufferSize:     4096,
+		FlushInterval:  time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trl.Init()
+	trl.Info("buffered")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := trl.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	trl.Close()
+	if err := trl.Shutdown(ctx); err != ErrLoggerClosed {
+		t.Fatalf("Expected %v, but received %v", ErrLoggerClosed, err)
+	}
+	if _, err := trl.Write([]byte("after\n")); err != ErrLoggerClosed {
+		t.Fatalf("Expected %v, but received %v", ErrLoggerClosed, err)
+	}
+	content, err := ioutil.ReadFile(trl.writer.Filename())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "INFO * buffered\n") {
+		t.Fatalf("Expected the entry flushed, but found '%s'", content)
 	}
 }
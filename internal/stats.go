@@ -0,0 +1,128 @@
+package logs
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Stats is a snapshot of the counters and gauges of a logger. The rotation, compression and file fields are filled
+// only by the loggers writing to files
+type Stats struct {
+	// Entries logged per level
+	Entries      map[LoggerLevelMode]uint64
+	BytesWritten uint64
+	WriteErrors  uint64
+	Rotations    uint64
+	FilesRemoved uint64
+	// FilesCompressed, CompressionDuration, UncompressedBytes and CompressedBytes are the totals of the compressions
+	FilesCompressed     uint64
+	CompressionDuration time.Duration
+	UncompressedBytes   uint64
+	CompressedBytes     uint64
+	CurrentFileSize     int64
+	// HeldFiles are the files older than the retention kept only because of a legal hold
+	HeldFiles []string
+}
+
+// CompressionRatio is the compressed size over the uncompressed size of every file compressed, zero when none was
+func (s Stats) CompressionRatio() float64 {
+	if s.UncompressedBytes == 0 {
+		return 0
+	}
+	return float64(s.CompressedBytes) / float64(s.UncompressedBytes)
+}
+
+// WritePrometheus writes the stats in the Prometheus text exposition format, with the metrics prefixed by logs_
+func (s Stats) WritePrometheus(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	writeMetricHeader(bw, "logs_entries_total", "counter", "Entries logged per level.")
+	for _, level := range LogsMode {
+		fmt.Fprintf(bw, "logs_entries_total{level=%q} %d\n", level, s.Entries[level])
+	}
+	writeMetric(bw, "logs_bytes_written_total", "counter", "Bytes written to the sink.", strconv.FormatUint(s.BytesWritten, 10))
+	writeMetric(bw, "logs_write_errors_total", "counter", "Writes to the sink that failed.", strconv.FormatUint(s.WriteErrors, 10))
+	writeMetric(bw, "logs_rotations_total", "counter", "Rotations performed.", strconv.FormatUint(s.Rotations, 10))
+	writeMetric(bw, "logs_files_removed_total", "counter", "Old files removed.", strconv.FormatUint(s.FilesRemoved, 10))
+	writeMetric(bw, "logs_files_compressed_total", "counter", "Rotated files compressed.", strconv.FormatUint(s.FilesCompressed, 10))
+	writeMetric(bw, "logs_compression_seconds_total", "counter", "Time spent compressing the rotated files.", strconv.FormatFloat(s.CompressionDuration.Seconds(), 'g', -1, 64))
+	writeMetric(bw, "logs_compression_ratio", "gauge", "Compressed size over uncompressed size of the files compressed.", strconv.FormatFloat(s.CompressionRatio(), 'g', -1, 64))
+	writeMetric(bw, "logs_current_file_size_bytes", "gauge", "Size of the file being written.", strconv.FormatInt(s.CurrentFileSize, 10))
+	writeMetric(bw, "logs_held_files", "gauge", "Files older than the retention kept by a legal hold.", strconv.Itoa(len(s.HeldFiles)))
+	return bw.Flush()
+}
+
+func writeMetricHeader(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func writeMetric(w io.Writer, name, kind, help, value string) {
+	writeMetricHeader(w, name, kind, help)
+	fmt.Fprintf(w, "%s %s\n", name, value)
+}
+
+// Counters counts the entries and the writes of a logger. It is safe for concurrent use and a nil Counters counts
+// nothing
+type Counters struct {
+	// only uint64 fields, so they are aligned for the atomic operations
+	entries      [5]uint64
+	bytesWritten uint64
+	writeErrors  uint64
+}
+
+// CountEntry counts an entry logged with the level
+func (c *Counters) CountEntry(level LoggerLevelMode) {
+	if c == nil {
+		return
+	}
+	for i, l := range LogsMode {
+		if l == level {
+			atomic.AddUint64(&c.entries[i], 1)
+			return
+		}
+	}
+}
+
+// CountWrite counts the bytes written to the sink and the error, if any
+func (c *Counters) CountWrite(n int, err error) {
+	if c == nil {
+		return
+	}
+	if n > 0 {
+		atomic.AddUint64(&c.bytesWritten, uint64(n))
+	}
+	if err != nil {
+		atomic.AddUint64(&c.writeErrors, 1)
+	}
+}
+
+// Stats returns a snapshot of the counters, the other fields of Stats are left empty
+func (c *Counters) Stats() Stats {
+	s := Stats{Entries: make(map[LoggerLevelMode]uint64, len(LogsMode))}
+	for i, l := range LogsMode {
+		s.Entries[l] = 0
+		if c != nil {
+			s.Entries[l] = atomic.LoadUint64(&c.entries[i])
+		}
+	}
+	if c != nil {
+		s.BytesWritten = atomic.LoadUint64(&c.bytesWritten)
+		s.WriteErrors = atomic.LoadUint64(&c.writeErrors)
+	}
+	return s
+}
+
+// countingWriter counts the writes to the sink set with SetWriter
+type countingWriter struct {
+	io.Writer
+	counters *Counters
+}
+
+func (cw countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.Writer.Write(p)
+	cw.counters.CountWrite(n, err)
+	return n, err
+}
@@ -0,0 +1,67 @@
+package logs
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSimpleLoggerStats(t *testing.T) {
+	setup()
+	sl.Info("teste")
+	sl.Infof("teste %d", 2)
+	sl.Warn("teste")
+	s := sl.Stats()
+	if s.Entries[LogInfoMode] != 2 || s.Entries[LogWarnMode] != 1 || s.Entries[LogDebugMode] != 0 {
+		t.Fatalf("Unexpected entries %v", s.Entries)
+	}
+	if s.BytesWritten == 0 || s.WriteErrors != 0 {
+		t.Fatalf("Unexpected writes %+v", s)
+	}
+}
+
+func TestCountersCountWriteErrors(t *testing.T) {
+	var c Counters
+	c.CountWrite(10, nil)
+	c.CountWrite(2, errors.New("short write"))
+	if s := c.Stats(); s.BytesWritten != 12 || s.WriteErrors != 1 {
+		t.Fatalf("Unexpected stats %+v", s)
+	}
+	var nilCounters *Counters
+	nilCounters.CountEntry(LogInfoMode)
+	if s := nilCounters.Stats(); s.Entries[LogInfoMode] != 0 {
+		t.Fatalf("Unexpected stats %+v", s)
+	}
+}
+
+func TestStatsWritePrometheus(t *testing.T) {
+	s := Stats{
+		Entries:             map[LoggerLevelMode]uint64{LogInfoMode: 3},
+		BytesWritten:        120,
+		Rotations:           2,
+		CompressionDuration: 1500 * time.Millisecond,
+		UncompressedBytes:   1000,
+		CompressedBytes:     250,
+		HeldFiles:           []string{"app-20261017.log.gz"},
+	}
+	var buf bytes.Buffer
+	if err := s.WritePrometheus(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range []string{
+		"# TYPE logs_entries_total counter\n",
+		"logs_entries_total{level=\"INFO\"} 3\n",
+		"logs_entries_total{level=\"DEBUG\"} 0\n",
+		"logs_bytes_written_total 120\n",
+		"logs_rotations_total 2\n",
+		"logs_compression_seconds_total 1.5\n",
+		"logs_compression_ratio 0.25\n",
+		"logs_held_files 1\n",
+	} {
+		if !strings.Contains(buf.String(), e) {
+			t.Fatalf("Expected '%s' in '%s'", e, buf.String())
+		}
+	}
+}
@@ -1,13 +1,20 @@
 package logs
 
 import (
+	"context"
 	"errors"
 	"io"
 	"log"
-	"os"
 	"strings"
 
 	logs "github.com/Murilovisque/logs/v3/internal"
+	"github.com/Murilovisque/logs/v3/internal/fileutil"
+	"github.com/Murilovisque/logs/v3/internal/reopening"
+)
+
+type (
+	FileOptions = reopening.Options
+	FileOwner   = fileutil.Owner
 )
 
 var (
@@ -32,6 +39,14 @@ func InitWithLogFile(level logs.LoggerLevelMode, filename string, fixedValues ..
 	return initGlobalLogger(level, l)
 }
 
+func InitWithLogFileOptions(level logs.LoggerLevelMode, filename string, opts FileOptions, fixedValues ...logs.FieldValue) error {
+	l, err := reopening.NewFileLogger(level, filename, opts, fixedValues...)
+	if err != nil {
+		return err
+	}
+	return initGlobalLogger(level, l)
+}
+
 func InitWithWriter(level logs.LoggerLevelMode, w io.Writer, fixedValues ...logs.FieldValue) error {
 	return initGlobalLogger(level, newLoggerWithWriter(level, w, fixedValues...))
 }
@@ -56,6 +71,25 @@ func Close() {
 	globalLogger.Close()
 }
 
+// Shutdown flushes, syncs and closes the file of the globalLogger, stopping its goroutines, waiting until ctx is
+// done. It can be called many times, even concurrently, and returns ctx.Err() when ctx is done before the closing.
+// Only the first call returns the error of the closing, the other ones return the error of the logger being closed
+func Shutdown(ctx context.Context) error {
+	return globalLogger.Shutdown(ctx)
+}
+
+// Reopen reopens the log file of the globalLogger, e.g. after it was moved by an external logrotate. It does nothing if the globalLogger does not write to a file
+func Reopen() error {
+	if r, ok := globalLogger.(reopener); ok {
+		return r.Reopen()
+	}
+	return nil
+}
+
+type reopener interface {
+	Reopen() error
+}
+
 func StringToLoggerLevelMode(level string) (logs.LoggerLevelMode, error) {
 	level = strings.ToUpper(level)
 	for _, l := range logs.LogsMode {
@@ -81,11 +115,11 @@ func newLoggerWithWriter(level logs.LoggerLevelMode, w io.Writer, fixedValues ..
 }
 
 func newLoggerWithLogFile(level logs.LoggerLevelMode, filename string, fixedValues ...logs.FieldValue) (logs.Logger, error) {
-	f, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	l, err := reopening.NewFileLogger(level, filename, reopening.Options{}, fixedValues...)
 	if err != nil {
 		return nil, err
 	}
-	return newLoggerWithWriter(level, f, fixedValues...), nil
+	return l, nil
 }
 
 // Fatal logs using the globalLogger
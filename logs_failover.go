@@ -0,0 +1,45 @@
+package logs
+
+import (
+	"context"
+	"io"
+
+	"github.com/Murilovisque/logs/v3/failover"
+	logs "github.com/Murilovisque/logs/v3/internal"
+)
+
+type (
+	FailoverSink    = failover.Sink
+	FailoverOptions = failover.Options
+)
+
+// InitWithFailover logs to the primary sink, switching to the fallbacks while it fails, e.g. a rotating file with
+// os.Stderr as fallback. It fails only if no sink can be opened
+func InitWithFailover(level logs.LoggerLevelMode, primary FailoverSink, fallbacks []FailoverSink, opts FailoverOptions, fixedValues ...logs.FieldValue) error {
+	w, err := failover.New(primary, fallbacks, opts)
+	if err != nil {
+		return err
+	}
+	return initGlobalLogger(level, &closingLogger{Logger: newLoggerWithWriter(level, w, fixedValues...), closer: w})
+}
+
+type shutdowner interface {
+	Shutdown(ctx context.Context) error
+}
+
+// closingLogger closes the writer of the logger on Close
+type closingLogger struct {
+	logs.Logger
+	closer io.Closer
+}
+
+func (cl *closingLogger) Close() {
+	cl.closer.Close()
+}
+
+func (cl *closingLogger) Shutdown(ctx context.Context) error {
+	if sd, ok := cl.closer.(shutdowner); ok {
+		return sd.Shutdown(ctx)
+	}
+	return cl.closer.Close()
+}
@@ -0,0 +1,63 @@
+package logs
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Murilovisque/logs/v3/failover"
+	logs "github.com/Murilovisque/logs/v3/internal"
+)
+
+func TestInitWithFailoverWhenPrimaryCannotBeOpened(t *testing.T) {
+	primary := FailoverSink{Name: "primary", Open: func() (io.Writer, error) {
+		return nil, errors.New("permission denied")
+	}}
+	var events []failover.SwitchEvent
+	err := InitWithFailover(logs.LogDebugMode, primary, []FailoverSink{failover.WriterSink("fallback", &logWriter)}, FailoverOptions{
+		OnSwitch: func(e failover.SwitchEvent) { events = append(events, e) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+	Info("teste")
+	logWriter.assertLogMessage(t, "INFO * teste\n")
+	if len(events) != 1 || events[0].To != "fallback" || !strings.Contains(events[0].Err.Error(), "permission denied") {
+		t.Fatalf("Unexpected events %v", events)
+	}
+}
+
+func TestInitWithFailoverWhenOnSwitchLogs(t *testing.T) {
+	primary := FailoverSink{Name: "primary", Open: func() (io.Writer, error) {
+		return failingWriter{}, nil
+	}}
+	var fallback logWriterTest
+	logged := make(chan struct{}, 1)
+	err := InitWithFailover(logs.LogDebugMode, primary, []FailoverSink{failover.WriterSink("fallback", &fallback)}, FailoverOptions{
+		OnSwitch: func(e failover.SwitchEvent) {
+			Warnf("switched from %s to %s", e.From, e.To)
+			logged <- struct{}{}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+	select {
+	case <-logged:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Expected the switch logged, but the logger is blocked")
+	}
+	fallback.assertLogMessage(t, "WARN * switched from primary to fallback\n")
+	Info("teste")
+	fallback.assertLogMessage(t, "INFO * teste\n")
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk failed")
+}
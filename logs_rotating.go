@@ -3,9 +3,11 @@ package logs
 import (
 	"errors"
 	"strings"
+	"time"
 
 	logs "github.com/Murilovisque/logs/v3/internal"
 	"github.com/Murilovisque/logs/v3/internal/rotating"
+	"github.com/Murilovisque/logs/v3/rotatingfile"
 )
 
 const (
@@ -13,8 +15,15 @@ const (
 	RotatingSchemaPerHour = rotating.PerHour
 )
 
+type (
+	RotatingOptions = rotating.Options
+	Compressor      = rotatingfile.Compressor
+)
+
 var (
 	errTimeRotatingSchemeConversion = errors.New("time rotationg scheme conversion failed")
+	ErrRotationNotSupported         = errors.New("global logger does not rotate files")
+	ErrLegalHoldNotSupported        = errors.New("global logger does not support legal holds")
 )
 
 func InitWithRotatingLogFile(level logs.LoggerLevelMode, filename string, rotatingScheme rotating.TimeRotatingScheme, amountOfFilesToRetain int, compressOldFiles bool, fixedValues ...logs.FieldValue) error {
@@ -25,6 +34,60 @@ func InitWithRotatingLogFile(level logs.LoggerLevelMode, filename string, rotati
 	return initGlobalLogger(level, l)
 }
 
+// InitWithRotatingLogFileOptions logs to the rotating files configured by opts, or to a circular file of fixed size
+// when opts.CircularSize is set, which overwrites the oldest entries when it is full. The entries of the circular file
+// are read in order with circularfile.NewReader
+func InitWithRotatingLogFileOptions(level logs.LoggerLevelMode, filename string, opts RotatingOptions, fixedValues ...logs.FieldValue) error {
+	l, err := rotating.NewLoggerWithOptions(level, filename, opts, fixedValues...)
+	if err != nil {
+		return err
+	}
+	return initGlobalLogger(level, l)
+}
+
+// NewGzipCompressor creates a compressor producing .gz files. The level must be between gzip.HuffmanOnly and gzip.BestCompression
+func NewGzipCompressor(level int) (Compressor, error) {
+	return rotatingfile.NewGzipCompressor(level)
+}
+
+// NewZipCompressor creates a compressor producing .zip files
+func NewZipCompressor() Compressor {
+	return rotatingfile.NewZipCompressor()
+}
+
+// Rotate rotates the file of the globalLogger immediately
+func Rotate() error {
+	if r, ok := globalLogger.(rotator); ok {
+		return r.Rotate()
+	}
+	return ErrRotationNotSupported
+}
+
+type rotator interface {
+	Rotate() error
+}
+
+// Hold places a legal hold on the files of the globalLogger of the periods overlapping [from, to), they are never removed by the retention
+func Hold(from, to time.Time) error {
+	if h, ok := globalLogger.(holder); ok {
+		return h.Hold(from, to)
+	}
+	return ErrLegalHoldNotSupported
+}
+
+// Release removes the legal hold of the globalLogger placed with the same interval
+func Release(from, to time.Time) error {
+	if h, ok := globalLogger.(holder); ok {
+		return h.Release(from, to)
+	}
+	return ErrLegalHoldNotSupported
+}
+
+type holder interface {
+	Hold(from, to time.Time) error
+	Release(from, to time.Time) error
+}
+
 func StringToTimeRotatingScheme(s string) (rotating.TimeRotatingScheme, error) {
 	s = strings.ToUpper(s)
 	switch s {
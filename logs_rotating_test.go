@@ -2,7 +2,9 @@ package logs
 
 import (
 	"testing"
+	"time"
 
+	logs "github.com/Murilovisque/logs/v3/internal"
 	"github.com/Murilovisque/logs/v3/internal/rotating"
 )
 
@@ -26,3 +28,21 @@ func TestShouldConvertStringToTimeScheme(t *testing.T) {
 	}
 
 }
+
+func TestRotateWithoutRotatingLogger(t *testing.T) {
+	InitWithWriter(logs.LogDebugMode, &logWriter)
+	if err := Rotate(); err != ErrRotationNotSupported {
+		t.Fatalf("Expected %v, but received %v", ErrRotationNotSupported, err)
+	}
+}
+
+func TestHoldWithoutRotatingLogger(t *testing.T) {
+	InitWithWriter(logs.LogDebugMode, &logWriter)
+	now := time.Now()
+	if err := Hold(now, now.Add(time.Hour)); err != ErrLegalHoldNotSupported {
+		t.Fatalf("Expected %v, but received %v", ErrLegalHoldNotSupported, err)
+	}
+	if err := Release(now, now.Add(time.Hour)); err != ErrLegalHoldNotSupported {
+		t.Fatalf("Expected %v, but received %v", ErrLegalHoldNotSupported, err)
+	}
+}
@@ -0,0 +1,29 @@
+package logs
+
+import (
+	"expvar"
+	"io"
+
+	logs "github.com/Murilovisque/logs/v3/internal"
+)
+
+type LoggerStats = logs.Stats
+
+// Stats returns the counters and gauges of the globalLogger
+func Stats() LoggerStats {
+	return globalLogger.Stats()
+}
+
+// WritePrometheus writes the stats of the globalLogger in the Prometheus text exposition format, e.g. from the
+// handler of a /metrics endpoint
+func WritePrometheus(w io.Writer) error {
+	return globalLogger.Stats().WritePrometheus(w)
+}
+
+// PublishExpvar publishes the stats of the globalLogger as the expvar name, read when the variable is. As
+// expvar.Publish, it panics if the name is already published
+func PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return globalLogger.Stats()
+	}))
+}
@@ -0,0 +1,34 @@
+package logs
+
+import (
+	"bytes"
+	"encoding/json"
+	"expvar"
+	"strings"
+	"testing"
+
+	logs "github.com/Murilovisque/logs/v3/internal"
+)
+
+func TestStatsOfGlobalLogger(t *testing.T) {
+	InitWithWriter(logs.LogDebugMode, &logWriter)
+	Warn("teste")
+	if s := Stats(); s.Entries[LevelWarn] != 1 || s.Entries[LevelInfo] != 1 {
+		t.Fatalf("Unexpected entries %v", s.Entries)
+	}
+	var buf bytes.Buffer
+	if err := WritePrometheus(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "logs_entries_total{level=\"WARN\"} 1\n") {
+		t.Fatalf("Unexpected metrics '%s'", buf.String())
+	}
+	PublishExpvar("teste_logs")
+	var s LoggerStats
+	if err := json.Unmarshal([]byte(expvar.Get("teste_logs").String()), &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Entries[LevelWarn] != 1 {
+		t.Fatalf("Unexpected published entries %v", s.Entries)
+	}
+}
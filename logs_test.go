@@ -1,6 +1,7 @@
 package logs
 
 import (
+	"context"
 	"strings"
 	"testing"
 
@@ -55,3 +56,12 @@ func (w *logWriterTest) assertLogMessage(t *testing.T, m string) {
 		t.Fatalf("Expected '%s', but '%s' was logged\n", m, w.lastLog)
 	}
 }
+
+func TestShutdownIsIdempotent(t *testing.T) {
+	InitWithWriter(logs.LogDebugMode, &logWriter)
+	for i := 0; i < 2; i++ {
+		if err := Shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package rotatingfile
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Murilovisque/logs/v3/internal/compressor"
+	"github.com/Murilovisque/logs/v3/internal/fileutil"
+)
+
+// relocatePath replaces the static directory of the template in filename by dir, keeping the directories created
+// by the tokens, e.g. /var/log/2026/10/17/app.log is archived as <dir>/2026/10/17/app.log
+func relocatePath(filename, staticDir, dir string) string {
+	if staticDir != "." && strings.HasPrefix(filename, staticDir) {
+		return dir + filename[len(staticDir):]
+	}
+	return filepath.Join(dir, filename)
+}
+
+// relocate returns the template of the files moved to dir
+func (ft *filenameTemplate) relocate(dir string) *filenameTemplate {
+	relocated := filenameTemplate{hostname: ft.hostname}
+	relocated.parts = append(relocated.parts, ft.parts...)
+	staticDir := ft.staticDir()
+	if len(relocated.parts) > 0 && relocated.parts[0].token == "" {
+		relocated.parts[0].literal = relocatePath(relocated.parts[0].literal, staticDir, dir)
+	} else {
+		relocated.parts = append([]templatePart{{literal: dir + string(filepath.Separator)}}, relocated.parts...)
+	}
+	relocated.glob = relocated.buildGlob()
+	return &relocated
+}
+
+func archivePath(filename string, w *Writer) string {
+	return relocatePath(filename, w.nameTemplate().staticDir(), w.archiveDir)
+}
+
+// archiveFile moves the finished file to the archive directory, updating its path
+func archiveFile(filename string, w *Writer, f *RotatedFile) {
+	if w.archiveDir == "" {
+		return
+	}
+	archivedFilename := archivePath(filename, w)
+	if err := moveFile(filename, archivedFilename, w.permissions); err != nil {
+		w.reportError(OpArchive, filename, err)
+		return
+	}
+	f.Path = archivedFilename
+	f.Archived = true
+	w.logger.Debugf("File %s archived to %s", filename, archivedFilename)
+}
+
+// moveFile renames the file, or copies and removes it when the rename fails, e.g. between filesystems
+func moveFile(source, destination string, perms fileutil.Permissions) error {
+	if err := perms.MkdirAll(filepath.Dir(destination)); err != nil {
+		return err
+	}
+	if err := os.Rename(source, destination); err == nil {
+		return nil
+	}
+	tempDestination := destination + compressor.TempExtension
+	if err := copyFile(source, tempDestination, perms); err != nil {
+		os.Remove(tempDestination)
+		return err
+	}
+	if err := os.Rename(tempDestination, destination); err != nil {
+		os.Remove(tempDestination)
+		return err
+	}
+	fileutil.SyncDir(filepath.Dir(destination))
+	return os.Remove(source)
+}
+
+func copyFile(source, destination string, perms fileutil.Permissions) error {
+	sourceFile, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer sourceFile.Close()
+	destinationFile, err := perms.OpenFile(destination, os.O_CREATE|os.O_TRUNC|os.O_WRONLY)
+	if err != nil {
+		return err
+	}
+	defer destinationFile.Close()
+	if _, err = io.Copy(destinationFile, sourceFile); err != nil {
+		return err
+	}
+	if err = destinationFile.Sync(); err != nil {
+		return err
+	}
+	return destinationFile.Close()
+}
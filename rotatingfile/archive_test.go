@@ -0,0 +1,125 @@
+package rotatingfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Murilovisque/logs/v3/internal/fileutil"
+)
+
+func TestRelocatePath(t *testing.T) {
+	tests := []struct {
+		filename  string
+		staticDir string
+		dir       string
+		exp       string
+	}{
+		{"/var/log/app-20121207.log", "/var/log", "/archive", "/archive/app-20121207.log"},
+		{"/var/log/2012/12/07/app.log", "/var/log", "/archive", "/archive/2012/12/07/app.log"},
+		{"app-20121207.log", ".", "/archive", "/archive/app-20121207.log"},
+	}
+	for _, test := range tests {
+		if vl := relocatePath(test.filename, test.staticDir, test.dir); vl != test.exp {
+			t.Fatalf("Expected %s, but received %s", test.exp, vl)
+		}
+	}
+}
+
+func TestRelocateTemplate(t *testing.T) {
+	now, _ := time.Parse("2006 Jan 02", "2012 Dec 07")
+	tests := []struct {
+		ft  *filenameTemplate
+		exp string
+	}{
+		{defaultFilenameTemplate("/var/log/app.log", PerDay), "/archive/app-20121207.log"},
+		{defaultFilenameTemplate("app.log", PerDay), "/archive/app-20121207.log"},
+	}
+	ft, err := parseFilenameTemplate("/var/log/%Y/%m/%d/app{seq}.log", PerDay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests = append(tests, struct {
+		ft  *filenameTemplate
+		exp string
+	}{ft, "/archive/2012/12/07/app.log"})
+	for _, test := range tests {
+		if vl := test.ft.relocate("/archive").format(now, 0); vl != test.exp {
+			t.Fatalf("Expected %s, but received %s", test.exp, vl)
+		}
+	}
+}
+
+func TestCopyFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	source := filepath.Join(dir, "source.log")
+	if err := ioutil.WriteFile(source, []byte("conteúdo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	destination := filepath.Join(dir, "destination.log")
+	if err := copyFile(source, destination, fileutil.Permissions{}); err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(destination)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "conteúdo" {
+		t.Fatalf("Expected conteúdo, but received %s", content)
+	}
+}
+
+func TestRotateWithArchiveDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	archiveDir := filepath.Join(dir, "archive")
+	oldArchivedFile := filepath.Join(archiveDir, "teste-20121207.log.gz")
+	if err := os.MkdirAll(archiveDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(oldArchivedFile, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewGzipCompressor(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(dir, "teste.log")
+	w, err := New(filename, Options{RotatingScheme: PerDay, Compressor: c, ArchiveDir: archiveDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	archivedFile := filepath.Join(archiveDir, filepath.Base(buildFilenameWithTimeExtension(now, filename, PerDay))+".gz")
+	if _, err := os.Stat(archivedFile); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(buildFilenameWithSequence(now, filename, PerDay, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(oldArchivedFile); !os.IsNotExist(err) {
+		t.Fatalf("Old archived file should be removed, but stat returned %v", err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "teste-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("Only the current file should remain, but found %v", files)
+	}
+}
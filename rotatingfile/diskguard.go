@@ -0,0 +1,164 @@
+package rotatingfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/Murilovisque/logs/v3/internal/compressor"
+)
+
+// DiskState is the level of free space seen by the disk guard
+type DiskState int32
+
+const (
+	// DiskOK means there is enough free space
+	DiskOK DiskState = iota
+	// DiskLow means the loggers drop the entries below WARN
+	DiskLow
+	// DiskFull means the writes are dropped until there is free space again
+	DiskFull
+)
+
+const defaultDiskCheckInterval = 10 * time.Second
+
+var (
+	ErrDiskFull                = errors.New("rotating file writer stopped, the disk is almost full")
+	ErrInvalidDiskGuardOptions = errors.New("disk guard thresholds must be CleanupBelow >= DropBelow >= StopBelow")
+	ErrDiskSpaceNotSupported   = errors.New("free disk space is not supported on this platform")
+)
+
+// DiskGuardOptions are the free space thresholds, in bytes, of the disk guard. A zero threshold disables its step
+type DiskGuardOptions struct {
+	// CheckInterval is how often the free space is checked. Zero means ten seconds
+	CheckInterval time.Duration
+	// CleanupBelow removes the oldest rotated files, even within the retention, until the free space is above it
+	CleanupBelow uint64
+	// DropBelow makes the loggers drop the entries below WARN
+	DropBelow uint64
+	// StopBelow stops writing, emitting a single alert to stderr, until the free space is above it again
+	StopBelow uint64
+}
+
+func (o *DiskGuardOptions) validate() error {
+	if o.CheckInterval < 0 {
+		return ErrInvalidDiskGuardOptions
+	}
+	if (o.DropBelow > 0 && o.CleanupBelow > 0 && o.CleanupBelow < o.DropBelow) ||
+		(o.StopBelow > 0 && o.DropBelow > 0 && o.DropBelow < o.StopBelow) ||
+		(o.StopBelow > 0 && o.CleanupBelow > 0 && o.CleanupBelow < o.StopBelow) {
+		return ErrInvalidDiskGuardOptions
+	}
+	return nil
+}
+
+// DiskState returns the state seen by the last check of the disk guard, always DiskOK without it
+func (w *Writer) DiskState() DiskState {
+	return DiskState(atomic.LoadInt32(&w.diskState))
+}
+
+func (w *Writer) diskCheckInterval() time.Duration {
+	if w.diskGuard.CheckInterval == 0 {
+		return defaultDiskCheckInterval
+	}
+	return w.diskGuard.CheckInterval
+}
+
+// guardingDisk checks the free space periodically. The ticker is reset after each check, as rotatingFile does
+func guardingDisk(w *Writer, tick Ticker) {
+	defer w.background.Done()
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C():
+			checkDiskSpace(w)
+			tick.Reset(w.diskCheckInterval())
+		case <-w.stopBackground:
+			return
+		}
+	}
+}
+
+// checkDiskSpace runs the emergency cleanup when needed and updates the state from the free space left
+func checkDiskSpace(w *Writer) {
+	dir := filepath.Dir(w.Filename())
+	free, err := w.freeSpace(dir)
+	if err != nil {
+		w.reportError(OpDiskSpace, dir, err)
+		return
+	}
+	if free < w.diskGuard.CleanupBelow {
+		free = emergencyCleanup(free, w)
+	}
+	state := DiskOK
+	if free < w.diskGuard.StopBelow {
+		state = DiskFull
+	} else if free < w.diskGuard.DropBelow {
+		state = DiskLow
+	}
+	previous := DiskState(atomic.SwapInt32(&w.diskState, int32(state)))
+	if state == DiskFull && previous != DiskFull {
+		fmt.Fprintf(w.alertOutput, "%s: log writes to %s stopped, %d bytes free on the disk\n", w.now().Format(time.RFC3339), w.filename, free)
+	} else if state != DiskFull && previous == DiskFull {
+		fmt.Fprintf(w.alertOutput, "%s: log writes to %s resumed, %d bytes free on the disk\n", w.now().Format(time.RFC3339), w.filename, free)
+	}
+}
+
+type rotatedFileEntry struct {
+	filename string
+	period   time.Time
+	ft       *filenameTemplate
+}
+
+// emergencyCleanup removes the oldest rotated files, ignoring the retention but not the retention guards, until
+// the free space is above CleanupBelow. It returns the free space left. As removeOldFiles, it waits the files being
+// finished
+func emergencyCleanup(free uint64, w *Writer) uint64 {
+	w.retentionMux.Lock()
+	defer w.retentionMux.Unlock()
+	w.waitFinishes()
+	holds := w.loadHolds()
+	var entries []rotatedFileEntry
+	current := w.Filename()
+	for _, ft := range w.templates() {
+		matcher, err := ft.matcher(w.finishedExtensionsRegex())
+		if err != nil {
+			continue
+		}
+		fileEntries, err := filepath.Glob(ft.glob)
+		if err != nil {
+			continue
+		}
+		for _, filename := range fileEntries {
+			if filename == current || filename == w.symlink || compressor.IsTempFile(filename) || isRetained(filename, holds, w) {
+				continue
+			}
+			if period, _, ok := matcher.match(filename, w.now().Location()); ok {
+				entries = append(entries, rotatedFileEntry{filename: filename, period: period, ft: ft})
+			}
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].period.Before(entries[j].period)
+	})
+	for _, e := range entries {
+		if free >= w.diskGuard.CleanupBelow {
+			break
+		}
+		if err := os.Remove(e.filename); err != nil {
+			w.reportError(OpRemove, e.filename, err)
+			continue
+		}
+		w.logger.Infof("File %s removed to free disk space", e.filename)
+		w.counters.countRemoval()
+		removeEmptyDirs(filepath.Dir(e.filename), e.ft)
+		if f, err := w.freeSpace(filepath.Dir(current)); err == nil {
+			free = f
+		}
+	}
+	return free
+}
@@ -0,0 +1,107 @@
+package rotatingfile
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiskGuard(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "teste.log")
+	now := time.Now()
+	old := []string{
+		buildFilenameWithTimeExtension(now.AddDate(0, 0, -2), filename, PerDay) + ".gz",
+		buildFilenameWithTimeExtension(now.AddDate(0, 0, -1), filename, PerDay) + ".gz",
+	}
+	for _, f := range old {
+		if err := ioutil.WriteFile(f, []byte("old"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w, err := Open(filename, Options{RotatingScheme: PerDay, AmountOfFilesToRetain: 5, DiskGuard: &DiskGuardOptions{
+		CleanupBelow: 300,
+		DropBelow:    250,
+		StopBelow:    100,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	var alerts bytes.Buffer
+	w.alertOutput = &alerts
+	// each removed file frees 100 bytes
+	free := uint64(200)
+	w.freeSpace = func(string) (uint64, error) {
+		n := uint64(0)
+		for _, f := range old {
+			if !fileExists(f) {
+				n += 100
+			}
+		}
+		return free + n, nil
+	}
+	checkDiskSpace(w)
+	if fileExists(old[0]) || !fileExists(old[1]) {
+		t.Fatal("Expected only the oldest file removed")
+	}
+	if w.DiskState() != DiskOK {
+		t.Fatalf("Expected %v, but received %v", DiskOK, w.DiskState())
+	}
+
+	free = 0
+	checkDiskSpace(w)
+	if fileExists(old[1]) || !fileExists(w.Filename()) {
+		t.Fatal("Expected the rotated files removed, but not the current one")
+	}
+	if w.DiskState() != DiskLow {
+		t.Fatalf("Expected %v, but received %v", DiskLow, w.DiskState())
+	}
+
+	free = 0
+	w.freeSpace = func(string) (uint64, error) {
+		return free, nil
+	}
+	checkDiskSpace(w)
+	checkDiskSpace(w)
+	if w.DiskState() != DiskFull {
+		t.Fatalf("Expected %v, but received %v", DiskFull, w.DiskState())
+	}
+	if _, err := w.Write([]byte("dropped\n")); err != ErrDiskFull {
+		t.Fatalf("Expected %v, but received %v", ErrDiskFull, err)
+	}
+	if n := strings.Count(alerts.String(), "stopped"); n != 1 {
+		t.Fatalf("Expected a single alert, but found %q", alerts.String())
+	}
+
+	free = 1000
+	checkDiskSpace(w)
+	if w.DiskState() != DiskOK || !strings.Contains(alerts.String(), "resumed") {
+		t.Fatalf("Expected the writes resumed, state %v and alerts %q", w.DiskState(), alerts.String())
+	}
+	if _, err := w.Write([]byte("written\n")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenWithInvalidDiskGuardOptions(t *testing.T) {
+	for _, opts := range []DiskGuardOptions{
+		{CheckInterval: -1},
+		{CleanupBelow: 100, DropBelow: 200},
+		{DropBelow: 100, StopBelow: 200},
+		{CleanupBelow: 100, StopBelow: 200},
+	} {
+		o := opts
+		if _, err := Open("teste.log", Options{RotatingScheme: PerDay, DiskGuard: &o}); err != ErrInvalidDiskGuardOptions {
+			t.Fatalf("Expected %v for %+v, but received %v", ErrInvalidDiskGuardOptions, opts, err)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package rotatingfile
+
+// diskFreeSpace is only supported on the platforms with statfs
+func diskFreeSpace(dir string) (uint64, error) {
+	return 0, ErrDiskSpaceNotSupported
+}
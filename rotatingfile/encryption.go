@@ -0,0 +1,76 @@
+package rotatingfile
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Murilovisque/logs/v3/internal/compressor"
+	"github.com/Murilovisque/logs/v3/internal/encryptor"
+)
+
+// EncryptedExtension is appended to the encrypted files, after the compression extension, e.g. app-20261017.log.gz.enc
+const EncryptedExtension = encryptor.Extension
+
+type (
+	// KeySource provides the AES-256 key of the encrypted files
+	KeySource = encryptor.KeySource
+	// StaticKey is a KeySource returning the same 32 bytes key
+	StaticKey = encryptor.StaticKey
+	// KeyFile is a KeySource reading the key from a file, either as 32 raw bytes or 64 hex characters
+	KeyFile = encryptor.KeyFile
+)
+
+var (
+	ErrInvalidKey          = encryptor.ErrInvalidKey
+	ErrInvalidEncryptedLog = encryptor.ErrInvalidFormat
+	ErrTruncatedEncrypted  = encryptor.ErrTruncated
+)
+
+// Decrypt writes the decrypted content of src to dst. The content is still compressed when the file was
+// compressed before being encrypted, e.g. a .gz.enc file decrypts to gzip data
+func Decrypt(dst io.Writer, src io.Reader, keys KeySource) error {
+	reader, err := encryptor.NewReader(src, keys)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, reader)
+	return err
+}
+
+// DecryptFile writes the decrypted content of the file to dst
+func DecryptFile(dst io.Writer, filename string, keys KeySource) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return Decrypt(dst, f, keys)
+}
+
+// finishedExtensionsRegex matches the extensions of a finished file: compressed, encrypted or both
+func (w *Writer) finishedExtensionsRegex() string {
+	return encryptor.ExtensionsRegex(compressor.ExtensionsRegex(w.compressor))
+}
+
+// encryptFile returns the name of the encrypted file, or filename when there is no encryption. It returns false when
+// the encryption fails, after reporting it, so the plain file is neither archived nor passed to the hooks
+func encryptFile(filename string, w *Writer) (string, bool) {
+	if w.encryptor == nil {
+		return filename, true
+	}
+	err := compressor.CompressFile(w.encryptor, filename, w.permissions)
+	if err != nil {
+		w.reportError(OpEncrypt, filename, err)
+		return filename, false
+	}
+	return filename + EncryptedExtension, true
+}
+
+func isEncrypted(finishedExt string) bool {
+	return strings.HasSuffix(finishedExt, EncryptedExtension)
+}
+
+func isCompressed(finishedExt string) bool {
+	return strings.TrimSuffix(finishedExt, EncryptedExtension) != ""
+}
@@ -0,0 +1,158 @@
+package rotatingfile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Murilovisque/logs/v3/internal/compressor"
+)
+
+func TestRotateWithEncryption(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "teste.log")
+	c, err := NewGzipCompressor(gzip.BestSpeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := StaticKey(bytes.Repeat([]byte{7}, 32))
+	var rotated []RotatedFile
+	w, err := New(filename, Options{RotatingScheme: PerDay, Compressor: c, Encryption: key, PostRotateHooks: []PostRotateHook{func(f RotatedFile) {
+		rotated = append(rotated, f)
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("secret\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	encrypted := buildFilenameWithTimeExtension(time.Now(), filename, PerDay) + compressor.GzipExtension + EncryptedExtension
+	if len(rotated) != 1 || rotated[0].Path != encrypted || !rotated[0].Compressed || !rotated[0].Encrypted {
+		t.Fatalf("Unexpected rotated files %+v", rotated)
+	}
+	var compressed bytes.Buffer
+	if err := DecryptFile(&compressed, encrypted, key); err != nil {
+		t.Fatal(err)
+	}
+	reader, err := gzip.NewReader(&compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "secret\n" {
+		t.Fatalf("Expected the original content, but found %q", content)
+	}
+}
+
+func TestFinishWhenEncryptionFails(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "teste.log")
+	archiveDir := filepath.Join(dir, "archive")
+	previous := buildFilenameWithTimeExtension(time.Now().AddDate(0, 0, -1), filename, PerDay)
+	if err := ioutil.WriteFile(previous, []byte("secret\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	key := StaticKey(bytes.Repeat([]byte{7}, 32))
+	var rotated []RotatedFile
+	var reported []*Error
+	opts := Options{
+		RotatingScheme:        PerDay,
+		AmountOfFilesToRetain: 7,
+		Encryption:            &failingKey{StaticKey: key},
+		ArchiveDir:            archiveDir,
+		PostRotateHooks:       []PostRotateHook{func(f RotatedFile) { rotated = append(rotated, f) }},
+		ErrorHandler:          func(err *Error) { reported = append(reported, err) },
+	}
+	w, err := New(filename, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WaitIdle()
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(rotated) > 0 {
+		t.Fatalf("Expected the hooks not called, but received %+v", rotated)
+	}
+	if len(reported) != 1 || reported[0].Op != OpEncrypt {
+		t.Fatalf("Expected the encryption error reported, but received %v", reported)
+	}
+	if _, err := os.Stat(previous); err != nil {
+		t.Fatalf("Expected the file left in place, but stat returned %v", err)
+	}
+	if archived, _ := ioutil.ReadDir(archiveDir); len(archived) > 0 {
+		t.Fatalf("Expected nothing archived, but found %v", archived)
+	}
+	// the file is finished at the next start
+	reported = nil
+	opts.Encryption = key
+	w, err = New(filename, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WaitIdle()
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(reported) > 0 || len(rotated) != 1 || !rotated[0].Encrypted || !rotated[0].Archived {
+		t.Fatalf("Expected the file encrypted and archived, but received %+v - Errors: %v", rotated, reported)
+	}
+}
+
+// failingKey fails after its first call, the one validating it when opening the Writer
+type failingKey struct {
+	StaticKey
+	calls int
+}
+
+func (k *failingKey) Key() ([]byte, error) {
+	if k.calls++; k.calls > 1 {
+		return nil, errors.New("key unavailable")
+	}
+	return k.StaticKey.Key()
+}
+
+func TestOpenWithInvalidKey(t *testing.T) {
+	if _, err := Open("teste.log", Options{RotatingScheme: PerDay, Encryption: StaticKey("short")}); err != ErrInvalidKey {
+		t.Fatalf("Expected %v, but received %v", ErrInvalidKey, err)
+	}
+}
+
+func TestMustEncryptedFileBeRemoved(t *testing.T) {
+	w := &Writer{filename: "/var/log/teste.log", rotatingScheme: PerDay, amountOfFilesToRetain: 1, logger: nopLogger{}}
+	lastFileTime := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	for filename, expected := range map[string]bool{
+		"/var/log/teste-20261016.log.gz.enc":  true,
+		"/var/log/teste-20261016.log.zip.enc": true,
+		"/var/log/teste-20261016.log.enc":     true,
+		"/var/log/teste-20261016.1.log.enc":   true,
+		"/var/log/teste-20261017.log.gz.enc":  false,
+		"/var/log/teste-20261016.log.enc.gz":  false,
+	} {
+		if mustFileBeRemoved(lastFileTime, filename, w) != expected {
+			t.Fatalf("Expected %v for %s", expected, filename)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package rotatingfile
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Operation of the writer that failed
+type Operation string
+
+const (
+	OpWrite     Operation = "write"
+	OpOpen      Operation = "open"
+	OpRotate    Operation = "rotate"
+	OpSymlink   Operation = "symlink"
+	OpFinish    Operation = "finish"
+	OpCompress  Operation = "compress"
+	OpEncrypt   Operation = "encrypt"
+	OpArchive   Operation = "archive"
+	OpRemove    Operation = "remove"
+	OpLock      Operation = "lock"
+	OpHold      Operation = "hold"
+	OpDiskSpace Operation = "disk space"
+)
+
+// DefaultErrorInterval is the minimum interval between the errors written by the default ErrorHandler
+const DefaultErrorInterval = time.Second
+
+// Error is an internal failure of the writer, reported to the ErrorHandler instead of the log being written
+type Error struct {
+	Op   Operation
+	Path string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	if e.Path == "" {
+		return string(e.Op) + ": " + e.Err.Error()
+	}
+	return string(e.Op) + " " + e.Path + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ErrorHandler receives the internal failures of the writer. It is called from the goroutine that failed, so it
+// must not block nor write to the writer
+type ErrorHandler func(err *Error)
+
+// NewRateLimitedErrorHandler writes the errors to out, at most one per interval. The errors suppressed in between
+// are counted in the next line written
+func NewRateLimitedErrorHandler(out io.Writer, interval time.Duration) ErrorHandler {
+	var mux sync.Mutex
+	var last time.Time
+	suppressed := 0
+	return func(err *Error) {
+		mux.Lock()
+		defer mux.Unlock()
+		now := time.Now()
+		if !last.IsZero() && now.Sub(last) < interval {
+			suppressed++
+			return
+		}
+		last = now
+		if suppressed > 0 {
+			fmt.Fprintf(out, "%s rotatingfile: %s (%d errors suppressed)\n", now.Format(time.RFC3339), err, suppressed)
+		} else {
+			fmt.Fprintf(out, "%s rotatingfile: %s\n", now.Format(time.RFC3339), err)
+		}
+		suppressed = 0
+	}
+}
+
+func defaultErrorHandler() ErrorHandler {
+	return NewRateLimitedErrorHandler(os.Stderr, DefaultErrorInterval)
+}
+
+// reportError passes the failure to the ErrorHandler
+func (w *Writer) reportError(op Operation, path string, err error) {
+	if w.errorHandler != nil {
+		w.errorHandler(&Error{Op: op, Path: path, Err: err})
+	}
+}
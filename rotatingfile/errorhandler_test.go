@@ -0,0 +1,117 @@
+package rotatingfile
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimitedErrorHandler(t *testing.T) {
+	var buf bytes.Buffer
+	handler := NewRateLimitedErrorHandler(&buf, 50*time.Millisecond)
+	err := &Error{Op: OpRemove, Path: "/var/log/app-20261017.log", Err: errors.New("permission denied")}
+	handler(err)
+	handler(err)
+	handler(err)
+	time.Sleep(60 * time.Millisecond)
+	handler(err)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, but found %q", lines)
+	}
+	if !strings.HasSuffix(lines[0], " rotatingfile: remove /var/log/app-20261017.log: permission denied") {
+		t.Fatalf("Unexpected line '%s'", lines[0])
+	}
+	if !strings.HasSuffix(lines[1], "permission denied (2 errors suppressed)") {
+		t.Fatalf("Unexpected line '%s'", lines[1])
+	}
+}
+
+func TestWriteErrorIsReported(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var reported []*Error
+	w, err := Open(filepath.Join(dir, "teste.log"), Options{
+		RotatingScheme: PerDay,
+		ErrorHandler: func(err *Error) {
+			reported = append(reported, err)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	filename := w.Filename()
+	w.file.Close()
+	if _, err := w.Write([]byte("teste\n")); err == nil {
+		t.Fatal("Expected the write to fail")
+	}
+	if len(reported) != 1 || reported[0].Op != OpWrite || reported[0].Path != filename || reported[0].Err == nil {
+		t.Fatalf("Unexpected errors reported %v", reported)
+	}
+}
+
+func TestDiskFullIsNotReported(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var reported []*Error
+	w, err := Open(filepath.Join(dir, "teste.log"), Options{
+		RotatingScheme: PerDay,
+		DiskGuard:      &DiskGuardOptions{StopBelow: 100},
+		ErrorHandler: func(err *Error) {
+			reported = append(reported, err)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.alertOutput = ioutil.Discard
+	w.freeSpace = func(string) (uint64, error) {
+		return 0, nil
+	}
+	checkDiskSpace(w)
+	for i := 0; i < 5; i++ {
+		if _, err := w.Write([]byte("dropped\n")); err != ErrDiskFull {
+			t.Fatalf("Expected %v, but received %v", ErrDiskFull, err)
+		}
+	}
+	if len(reported) != 0 {
+		t.Fatalf("Expected no errors reported, but received %v", reported)
+	}
+	if s := w.Stats(); s.WriteErrors != 0 {
+		t.Fatalf("Expected 0 write errors, but received %d", s.WriteErrors)
+	}
+}
+
+func TestInvalidHoldsAreReported(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var reported []*Error
+	w := &Writer{filename: filepath.Join(dir, "teste.log"), rotatingScheme: PerDay, errorHandler: func(err *Error) {
+		reported = append(reported, err)
+	}}
+	if err := ioutil.WriteFile(w.holdFilename(), []byte("invalid\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !w.loadHolds().Retain(filepath.Join(dir, "teste-20261017.log")) {
+		t.Fatal("Expected the file retained")
+	}
+	if len(reported) != 1 || reported[0].Op != OpHold || reported[0].Path != w.holdFilename() {
+		t.Fatalf("Unexpected errors reported %v", reported)
+	}
+}
@@ -0,0 +1,76 @@
+package rotatingfile
+
+import (
+	"io"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HeaderField is a metadata entry of the header written at the top of each new file
+type HeaderField struct {
+	Key   string
+	Value string
+}
+
+// HeaderFormatter encodes the header fields as the bytes written at the top of a file
+type HeaderFormatter func(fields []HeaderField) []byte
+
+// TextHeaderFormatter writes each field as a "# key: value" line
+func TextHeaderFormatter(fields []HeaderField) []byte {
+	var builder strings.Builder
+	for _, f := range fields {
+		builder.WriteString("# ")
+		builder.WriteString(f.Key)
+		builder.WriteString(": ")
+		builder.WriteString(f.Value)
+		builder.WriteString("\n")
+	}
+	return []byte(builder.String())
+}
+
+// headerFields returns the process metadata, the writer configuration and the extra fields of the header
+func headerFields(moment time.Time, filename, previousFilename string, w *Writer) []HeaderField {
+	fields := []HeaderField{{Key: "created", Value: moment.Format(time.RFC3339)}}
+	if hostname, err := os.Hostname(); err == nil {
+		fields = append(fields, HeaderField{Key: "hostname", Value: hostname})
+	}
+	fields = append(fields,
+		HeaderField{Key: "pid", Value: strconv.Itoa(os.Getpid())},
+		HeaderField{Key: "go", Value: runtime.Version()},
+	)
+	if info, ok := debug.ReadBuildInfo(); ok {
+		fields = append(fields, HeaderField{Key: "module", Value: info.Main.Path + " " + info.Main.Version})
+	}
+	fields = append(fields,
+		HeaderField{Key: "scheme", Value: string(w.rotatingScheme)},
+		HeaderField{Key: "file", Value: filename},
+	)
+	if previousFilename != "" {
+		fields = append(fields, HeaderField{Key: "previous", Value: previousFilename})
+	}
+	return append(fields, w.headerFields...)
+}
+
+// writeHeader writes the header to out when enabled and the file is empty, so a resumed file doesn't receive it twice
+func writeHeader(f *os.File, out io.Writer, moment time.Time, previousFilename string, w *Writer) error {
+	if !w.header {
+		return nil
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() > 0 {
+		return nil
+	}
+	format := w.headerFormatter
+	if format == nil {
+		format = TextHeaderFormatter
+	}
+	_, err = out.Write(format(headerFields(moment, f.Name(), previousFilename, w)))
+	return err
+}
@@ -0,0 +1,76 @@
+package rotatingfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTextHeaderFormatter(t *testing.T) {
+	b := TextHeaderFormatter([]HeaderField{{Key: "pid", Value: "1"}, {Key: "scheme", Value: "perDay"}})
+	if string(b) != "# pid: 1\n# scheme: perDay\n" {
+		t.Fatalf("Unexpected header %q", b)
+	}
+}
+
+func TestHeaderOnEveryNewFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "teste.log")
+	opts := Options{RotatingScheme: PerDay, Header: true, HeaderFields: []HeaderField{{Key: "level", Value: "INFO"}}}
+	w, err := New(filename, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("entry\n")); err != nil {
+		t.Fatal(err)
+	}
+	first := w.Filename()
+	if err := w.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	second := w.Filename()
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		filename string
+		expected []string
+	}{
+		{first, []string{"# pid: " + strconv.Itoa(os.Getpid()) + "\n", "# scheme: perDay\n", "# file: " + first + "\n", "# level: INFO\n", "entry\n"}},
+		{second, []string{"# previous: " + first + "\n"}},
+	} {
+		content, err := ioutil.ReadFile(tt.filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range tt.expected {
+			if !strings.Contains(string(content), e) {
+				t.Fatalf("Expected %q in %s, but found %q", e, tt.filename, content)
+			}
+		}
+	}
+
+	// a resumed file doesn't receive the header twice
+	w, err = New(filename, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(buildFilenameWithSequence(time.Now(), filename, PerDay, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(content), "# pid: "); n != 1 {
+		t.Fatalf("Expected one header, but found %d in %q", n, content)
+	}
+}
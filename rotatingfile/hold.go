@@ -0,0 +1,240 @@
+package rotatingfile
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Murilovisque/logs/v3/internal/compressor"
+	"github.com/Murilovisque/logs/v3/internal/fileutil"
+)
+
+// holdExtension is appended to the filename for the legal hold marker file, e.g. /var/log/app.log.hold
+const holdExtension = ".hold"
+
+var (
+	ErrInvalidHold  = errors.New("legal hold must end after it starts")
+	ErrHoldNotFound = errors.New("legal hold not found")
+)
+
+// Hold is a legal hold: the files of the periods overlapping [From, To) are never removed by the retention
+type Hold struct {
+	From time.Time
+	To   time.Time
+}
+
+func (h Hold) overlaps(start, end time.Time) bool {
+	return start.Before(h.To) && end.After(h.From)
+}
+
+// holdGuard is the RetentionGuard of the legal holds, read from the marker file once per retention pass. Each line
+// of the file is a hold as "<from> <to>" in RFC 3339, with the fraction of the second when there is one, so it can
+// also be edited by hand. An invalid file retains every file
+type holdGuard struct {
+	w       *Writer
+	holds   []Hold
+	invalid bool
+}
+
+// loadHolds reads the marker file for a retention pass, reporting when it is invalid
+func (w *Writer) loadHolds() holdGuard {
+	holds, err := w.Holds()
+	if err != nil {
+		w.reportError(OpHold, w.holdFilename(), err)
+	}
+	return holdGuard{w: w, holds: holds, invalid: err != nil}
+}
+
+func (g holdGuard) Retain(filename string) bool {
+	if g.invalid {
+		return true
+	}
+	if len(g.holds) == 0 {
+		return false
+	}
+	start, end, ok := filePeriod(filename, g.w)
+	if !ok {
+		return false
+	}
+	for _, h := range g.holds {
+		if h.overlaps(start, end) {
+			return true
+		}
+	}
+	return false
+}
+
+// Hold places a legal hold on the files of the periods overlapping [from, to), persisted in the marker file
+func (w *Writer) Hold(from, to time.Time) error {
+	if !to.After(from) {
+		return ErrInvalidHold
+	}
+	w.holdMux.Lock()
+	defer w.holdMux.Unlock()
+	holds, err := w.Holds()
+	if err != nil {
+		return err
+	}
+	return w.writeHolds(append(holds, Hold{From: from, To: to}))
+}
+
+// Release removes the legal hold placed with the same interval
+func (w *Writer) Release(from, to time.Time) error {
+	w.holdMux.Lock()
+	defer w.holdMux.Unlock()
+	holds, err := w.Holds()
+	if err != nil {
+		return err
+	}
+	for i, h := range holds {
+		if h.From.Equal(from) && h.To.Equal(to) {
+			return w.writeHolds(append(holds[:i], holds[i+1:]...))
+		}
+	}
+	return ErrHoldNotFound
+}
+
+// Holds returns the legal holds of the marker file
+func (w *Writer) Holds() ([]Hold, error) {
+	f, err := os.Open(w.holdFilename())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var holds []Hold
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid legal hold %q", line)
+		}
+		from, err := time.Parse(time.RFC3339Nano, fields[0])
+		if err != nil {
+			return nil, err
+		}
+		to, err := time.Parse(time.RFC3339Nano, fields[1])
+		if err != nil {
+			return nil, err
+		}
+		holds = append(holds, Hold{From: from, To: to})
+	}
+	return holds, scanner.Err()
+}
+
+// HeldFiles returns the files older than the retention kept only because of a legal hold, as found by the last
+// retention pass
+func (w *Writer) HeldFiles() []string {
+	w.holdMux.Lock()
+	defer w.holdMux.Unlock()
+	return append([]string(nil), w.heldFiles...)
+}
+
+// updateHeldFiles keeps the files held at the end of a retention pass, so HeldFiles does not search them
+func (w *Writer) updateHeldFiles(moment time.Time, guard holdGuard) {
+	held := findHeldFiles(moment, guard, w)
+	w.holdMux.Lock()
+	w.heldFiles = held
+	w.holdMux.Unlock()
+}
+
+func findHeldFiles(moment time.Time, guard holdGuard, w *Writer) []string {
+	if guard.invalid || len(guard.holds) == 0 {
+		return nil
+	}
+	lastFileTime := lastFileTimeToRetain(moment, w)
+	var held []string
+	for _, ft := range w.retentionTemplates() {
+		fileEntries, err := filepath.Glob(ft.glob)
+		if err != nil {
+			continue
+		}
+		for _, filename := range fileEntries {
+			if filename == w.symlink || compressor.IsTempFile(filename) {
+				continue
+			}
+			start, _, ok := filePeriod(filename, w)
+			if ok && start.Before(lastFileTime) && guard.Retain(filename) {
+				held = append(held, filename)
+			}
+		}
+	}
+	sort.Strings(held)
+	return held
+}
+
+func (w *Writer) holdFilename() string {
+	return w.filename + holdExtension
+}
+
+func (w *Writer) writeHolds(holds []Hold) error {
+	var buf bytes.Buffer
+	buf.WriteString("# legal holds: <from> <to>, the files of the periods in between are never removed\n")
+	for _, h := range holds {
+		fmt.Fprintf(&buf, "%s %s\n", h.From.Format(time.RFC3339Nano), h.To.Format(time.RFC3339Nano))
+	}
+	filename := w.holdFilename()
+	tempFilename := filename + compressor.TempExtension
+	temp, err := w.permissions.OpenFile(tempFilename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY)
+	if err != nil {
+		return err
+	}
+	_, err = temp.Write(buf.Bytes())
+	if err == nil {
+		err = temp.Sync()
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tempFilename, filename)
+	}
+	if err != nil {
+		os.Remove(tempFilename)
+		return err
+	}
+	fileutil.SyncDir(filepath.Dir(filename))
+	return nil
+}
+
+// retentionTemplates returns the templates of every file the retention may remove, including the daily archives
+func (w *Writer) retentionTemplates() []*filenameTemplate {
+	templates := w.templates()
+	if w.dailyArchives > 0 {
+		templates = append(templates, w.dailyTemplate())
+	}
+	return templates
+}
+
+// filePeriod returns the period of a rotated file or a daily archive
+func filePeriod(filename string, w *Writer) (time.Time, time.Time, bool) {
+	for _, ft := range w.templates() {
+		matcher, err := ft.matcher(w.finishedExtensionsRegex())
+		if err != nil {
+			continue
+		}
+		if start, _, ok := matcher.match(filename, w.now().Location()); ok {
+			return start, start.Add(w.rotatingScheme.rotatingInterval()), true
+		}
+	}
+	if w.dailyArchives > 0 {
+		if matcher, err := w.dailyTemplate().matcher(w.finishedExtensionsRegex()); err == nil {
+			if start, _, ok := matcher.match(filename, w.now().Location()); ok {
+				return start, start.AddDate(0, 0, 1), true
+			}
+		}
+	}
+	return time.Time{}, time.Time{}, false
+}
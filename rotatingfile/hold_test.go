@@ -0,0 +1,140 @@
+package rotatingfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHoldAndRelease(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	w := &Writer{filename: filepath.Join(dir, "teste.log"), logger: nopLogger{}}
+	from := time.Date(2012, 12, 6, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 1)
+	if err := w.Hold(to, from); err != ErrInvalidHold {
+		t.Fatalf("Expected %v, but received %v", ErrInvalidHold, err)
+	}
+	if err := w.Hold(from, to); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Hold(to, to.AddDate(0, 0, 1)); err != nil {
+		t.Fatal(err)
+	}
+	holds, err := w.Holds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(holds) != 2 || !holds[0].From.Equal(from) || !holds[0].To.Equal(to) {
+		t.Fatalf("Unexpected holds %v", holds)
+	}
+	if err := w.Release(from, to); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Release(from, to); err != ErrHoldNotFound {
+		t.Fatalf("Expected %v, but received %v", ErrHoldNotFound, err)
+	}
+	holds, err = w.Holds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(holds) != 1 || !holds[0].From.Equal(to) {
+		t.Fatalf("Unexpected holds %v", holds)
+	}
+}
+
+func TestReleaseWithFractionOfSecond(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	w := &Writer{filename: filepath.Join(dir, "teste.log"), logger: nopLogger{}}
+	from := time.Date(2012, 12, 6, 10, 30, 15, 123456789, time.Local)
+	to := from.Add(90 * time.Minute)
+	if err := w.Hold(from, to); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Release(from, to); err != nil {
+		t.Fatal(err)
+	}
+	holds, err := w.Holds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(holds) != 0 {
+		t.Fatalf("Expected no holds, but found %v", holds)
+	}
+	// the holds written by hand without the fraction are still read
+	content := "2012-12-06T10:30:15Z 2012-12-06T12:00:00Z\n"
+	if err := ioutil.WriteFile(w.holdFilename(), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Release(time.Date(2012, 12, 6, 10, 30, 15, 0, time.UTC), time.Date(2012, 12, 6, 12, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRetentionKeepsHeldFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "teste.log")
+	heldFile := filepath.Join(dir, "teste-20121206.log.zip")
+	removedFile := filepath.Join(dir, "teste-20121207.log.zip")
+	for _, f := range []string{heldFile, removedFile} {
+		if err := ioutil.WriteFile(f, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w, err := New(filename, Options{RotatingScheme: PerDay, Compressor: NewZipCompressor()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := time.Date(2012, 12, 6, 12, 0, 0, 0, time.Local)
+	if err := w.Hold(from, from.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(heldFile); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(removedFile); !os.IsNotExist(err) {
+		t.Fatalf("File %s should be removed, but stat returned %v", removedFile, err)
+	}
+	// the held files are the ones found by the last retention pass, the marker file is not read again
+	if err := os.Remove(w.holdFilename()); err != nil {
+		t.Fatal(err)
+	}
+	if held := w.HeldFiles(); len(held) != 1 || held[0] != heldFile {
+		t.Fatalf("Expected only %s held, but received %v", heldFile, held)
+	}
+}
+
+func TestInvalidHoldFileRetainsEveryFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "teste.log")
+	if err := ioutil.WriteFile(filename+holdExtension, []byte("2012-12-06\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w := &Writer{filename: filename, rotatingScheme: PerDay, logger: nopLogger{}}
+	if !w.loadHolds().Retain(filepath.Join(dir, "teste-20121207.log")) {
+		t.Fatal("Expected the file retained with an invalid hold file")
+	}
+}
@@ -0,0 +1,52 @@
+package rotatingfile
+
+import (
+	"os"
+	"time"
+)
+
+// RotatedFile describes a file finished after rotating
+type RotatedFile struct {
+	// Path is where the file is after being compressed and archived
+	Path string
+	// Period is the start of the rotating period of the file
+	Period     time.Time
+	Size       int64
+	Compressed bool
+	Encrypted  bool
+	Archived   bool
+}
+
+// PostRotateHook is called by the compression workers after a rotated file is finished, before the retention runs
+type PostRotateHook func(f RotatedFile)
+
+// RetentionGuard prevents the retention from removing files, e.g. the ones not shipped yet
+type RetentionGuard interface {
+	// Retain reports whether the file must be kept even if it is older than the retention
+	Retain(filename string) bool
+}
+
+func runPostRotateHooks(f RotatedFile, w *Writer) {
+	if len(w.postRotateHooks) == 0 {
+		return
+	}
+	if info, err := os.Stat(f.Path); err == nil {
+		f.Size = info.Size()
+	}
+	for _, hook := range w.postRotateHooks {
+		hook(f)
+	}
+}
+
+// isRetained checks the legal holds loaded by the retention pass, then the retention guards of the options
+func isRetained(filename string, holds holdGuard, w *Writer) bool {
+	if holds.Retain(filename) {
+		return true
+	}
+	for _, guard := range w.retentionGuards {
+		if guard.Retain(filename) {
+			return true
+		}
+	}
+	return false
+}
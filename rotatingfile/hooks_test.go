@@ -0,0 +1,76 @@
+package rotatingfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPostRotateHooksAndRetentionGuards(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "teste.log")
+	retainedFile := filepath.Join(dir, "teste-20121206.log")
+	removedFile := filepath.Join(dir, "teste-20121207.log")
+	for _, f := range []string{retainedFile, removedFile} {
+		if err := ioutil.WriteFile(f, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var mux sync.Mutex
+	rotatedFiles := []RotatedFile{}
+	hook := func(f RotatedFile) {
+		mux.Lock()
+		rotatedFiles = append(rotatedFiles, f)
+		mux.Unlock()
+	}
+	w, err := New(filename, Options{
+		RotatingScheme:  PerDay,
+		Compressor:      NewZipCompressor(),
+		PostRotateHooks: []PostRotateHook{hook},
+		RetentionGuards: []RetentionGuard{retentionGuardTest(retainedFile + ".zip")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("teste\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(retainedFile + ".zip"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(removedFile + ".zip"); !os.IsNotExist(err) {
+		t.Fatalf("File %s should be removed, but stat returned %v", removedFile, err)
+	}
+	if len(rotatedFiles) != 3 {
+		t.Fatalf("Expected 3 rotated files, but received %v", rotatedFiles)
+	}
+	now := time.Now()
+	for _, f := range rotatedFiles {
+		if f.Path == buildFilenameWithTimeExtension(now, filename, PerDay)+".zip" {
+			if !f.Compressed || f.Archived || f.Size == 0 || !f.Period.Equal(PerDay.truncate(time.Now())) {
+				t.Fatalf("Unexpected rotated file %v", f)
+			}
+			return
+		}
+	}
+	t.Fatalf("Current period file not found in %v", rotatedFiles)
+}
+
+type retentionGuardTest string
+
+func (g retentionGuardTest) Retain(filename string) bool {
+	return filename == string(g)
+}
@@ -0,0 +1,17 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package rotatingfile
+
+import "os"
+
+const fileLockSupported = false
+
+// lockFile is only supported on the platforms with flock
+func lockFile(f *os.File, exclusive, block bool) error {
+	return ErrMultiProcessNotSupported
+}
+
+func unlockFile(f *os.File) error {
+	return ErrMultiProcessNotSupported
+}
@@ -0,0 +1,141 @@
+package rotatingfile
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Murilovisque/logs/v3/internal/compressor"
+)
+
+const (
+	lockExtension = ".lock"
+	// busyFileRetries and busyFileRetryInterval give the other processes time to leave a rotated file
+	busyFileRetries       = 10
+	busyFileRetryInterval = 500 * time.Millisecond
+)
+
+var (
+	ErrMultiProcessNotSupported = errors.New("multi-process rotation is not supported on this platform")
+	errFileLocked               = errors.New("file is locked by another process")
+)
+
+// prepareFile writes the header of the new file and, in multi-process mode, takes a shared lock on it. The lock
+// tells the other processes that the file is still written, so it isn't compressed
+func prepareFile(f *os.File, out io.Writer, previousFilename string, w *Writer) error {
+	if !w.multiProcess {
+		return writeHeader(f, out, w.now(), previousFilename, w)
+	}
+	// only the process taking the exclusive lock writes the header, the file is already used by others otherwise
+	err := lockFile(f, true, false)
+	if err == nil {
+		err = writeHeader(f, out, w.now(), previousFilename, w)
+	} else if err == errFileLocked {
+		err = nil
+	}
+	if lockErr := lockFile(f, false, true); err == nil {
+		err = lockErr
+	}
+	return err
+}
+
+// withLeaderLock runs fn only if no other process is finishing and removing files, returning whether it ran
+func withLeaderLock(w *Writer, fn func()) bool {
+	lock, err := w.permissions.OpenFile(w.filename+lockExtension, os.O_CREATE|os.O_RDWR)
+	if err != nil {
+		w.reportError(OpLock, w.filename+lockExtension, err)
+		return false
+	}
+	defer lock.Close()
+	if err := lockFile(lock, true, false); err != nil {
+		if err != errFileLocked {
+			w.reportError(OpLock, lock.Name(), err)
+		}
+		return false
+	}
+	defer unlockFile(lock)
+	fn()
+	return true
+}
+
+// finishFilesAsLeader finishes the rotated files no process is writing and removes the old files, unless another
+// process is already doing it. The busy files are retried for a while, as the other processes rotate too
+func finishFilesAsLeader(moment time.Time, w *Writer) {
+	withLeaderLock(w, func() {
+		for attempt := 0; attempt < busyFileRetries; attempt++ {
+			if finishIdleFiles(w) == 0 || !w.sleep(busyFileRetryInterval) {
+				break
+			}
+		}
+		removeOldFiles(moment, w)
+	})
+}
+
+// sleep waits d on the clock, returning false when the writer is closed before
+func (w *Writer) sleep(d time.Duration) bool {
+	clock := w.clock
+	if clock == nil {
+		clock = realClock{}
+	}
+	tick := clock.NewTicker(d)
+	defer tick.Stop()
+	select {
+	case <-tick.C():
+		return true
+	case <-w.stopBackground:
+		return false
+	}
+}
+
+// finishIdleFiles finishes the rotated files not locked by any process, returning the amount of busy ones
+func finishIdleFiles(w *Writer) int {
+	matcher, err := rotatedFilenameMatcher(w)
+	if err != nil {
+		w.reportError(OpFinish, w.filename, err)
+		return 0
+	}
+	fileEntries, err := filepath.Glob(w.nameTemplate().glob)
+	if err != nil {
+		w.reportError(OpFinish, w.nameTemplate().glob, err)
+		return 0
+	}
+	current := w.Filename()
+	busy := 0
+	for _, filename := range fileEntries {
+		if filename == current || filename == w.symlink || compressor.IsTempFile(filename) {
+			continue
+		}
+		_, finishedExt, ok := matcher.match(filename, w.now().Location())
+		if !ok || !mustBeFinished(finishedExt, w) {
+			continue
+		}
+		if !finishIdleFile(filename, w) {
+			busy++
+		}
+	}
+	return busy
+}
+
+// mustBeFinished tells if the file still needs to be compressed, encrypted or archived
+func mustBeFinished(finishedExt string, w *Writer) bool {
+	return (finishedExt == "" && (w.compressor != nil || w.encryptor != nil)) ||
+		(w.encryptor != nil && !isEncrypted(finishedExt)) ||
+		w.archiveDir != ""
+}
+
+// finishIdleFile finishes the file holding an exclusive lock on it, returning false when a process still writes it
+func finishIdleFile(filename string, w *Writer) bool {
+	f, err := os.Open(filename)
+	if err != nil {
+		return true
+	}
+	defer f.Close()
+	if err := lockFile(f, true, false); err != nil {
+		return false
+	}
+	defer unlockFile(f)
+	finishFile(filename, w)
+	return true
+}
@@ -0,0 +1,158 @@
+package rotatingfile
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Murilovisque/logs/v3/internal/compressor"
+)
+
+func TestMultiProcessRotation(t *testing.T) {
+	if !fileLockSupported {
+		t.Skip("flock is not supported on " + runtime.GOOS)
+	}
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "teste.log")
+	c, err := NewGzipCompressor(gzip.BestSpeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{RotatingScheme: PerDay, AmountOfFilesToRetain: 5, Compressor: c, MultiProcess: true, Header: true}
+	// flock locks belong to the open file, so two writers in a process coordinate as two processes
+	writers := make([]*Writer, 2)
+	for i := range writers {
+		if writers[i], err = New(filename, opts); err != nil {
+			t.Fatal(err)
+		}
+		defer writers[i].Close()
+		writers[i].WaitIdle()
+	}
+	oldFilename := writers[0].Filename()
+	if writers[1].Filename() != oldFilename {
+		t.Fatalf("Expected both writers in %s, but the second is in %s", oldFilename, writers[1].Filename())
+	}
+	nextPeriod := writers[0].nowTruncated().AddDate(0, 0, 1)
+	for i, w := range writers {
+		if _, err := w.Write([]byte("before " + string(rune('0'+i)) + "\n")); err != nil {
+			t.Fatal(err)
+		}
+		if err := rotate(nextPeriod, false, w); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte("after " + string(rune('0'+i)) + "\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, w := range writers {
+		w.WaitIdle()
+	}
+	newFilename := writers[0].Filename()
+	if writers[1].Filename() != newFilename || newFilename == oldFilename {
+		t.Fatalf("Expected both writers in a new file, but found %s and %s", newFilename, writers[1].Filename())
+	}
+	if fileExists(oldFilename) || !fileExists(oldFilename+compressor.GzipExtension) {
+		t.Fatalf("Expected %s compressed once both writers left it", oldFilename)
+	}
+	archive, err := os.Open(oldFilename + compressor.GzipExtension)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+	reader, err := gzip.NewReader(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldContent, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(oldContent), "before 0\n") || !strings.Contains(string(oldContent), "before 1\n") {
+		t.Fatalf("Expected the entries of both writers in the compressed file, but found %q", oldContent)
+	}
+	content, err := ioutil.ReadFile(newFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(content), "# pid: ") != 1 || !strings.Contains(string(content), "after 0\n") || !strings.Contains(string(content), "after 1\n") {
+		t.Fatalf("Expected a single header and the entries of both writers, but found %q", content)
+	}
+}
+
+func TestWithLeaderLockIsExclusive(t *testing.T) {
+	if !fileLockSupported {
+		t.Skip("flock is not supported on " + runtime.GOOS)
+	}
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	w := &Writer{filename: filepath.Join(dir, "teste.log"), logger: nopLogger{}}
+	ran := withLeaderLock(w, func() {
+		if withLeaderLock(w, func() {}) {
+			t.Fatal("Expected the second leader lock to be refused")
+		}
+	})
+	if !ran || !withLeaderLock(w, func() {}) {
+		t.Fatal("Expected the leader lock to be taken when free")
+	}
+}
+
+func TestSleepWaitsTheClock(t *testing.T) {
+	clock := &tickedClock{now: time.Now()}
+	w := &Writer{clock: clock, stopBackground: make(chan struct{})}
+	if !w.sleep(busyFileRetryInterval) || clock.interval != busyFileRetryInterval || !clock.ticker.stopped {
+		t.Fatalf("Expected a tick of %v stopped after it, but received %+v", busyFileRetryInterval, clock)
+	}
+	clock.stalled = true
+	close(w.stopBackground)
+	if w.sleep(busyFileRetryInterval) {
+		t.Fatal("Expected the sleep interrupted by the closing")
+	}
+}
+
+// tickedClock returns tickers firing at once, unless it is stalled
+type tickedClock struct {
+	now      time.Time
+	interval time.Duration
+	stalled  bool
+	ticker   *tickedTicker
+}
+
+func (c *tickedClock) Now() time.Time {
+	return c.now
+}
+
+func (c *tickedClock) NewTicker(d time.Duration) Ticker {
+	c.interval = d
+	c.ticker = &tickedTicker{c: make(chan time.Time, 1)}
+	if !c.stalled {
+		c.ticker.c <- c.now.Add(d)
+	}
+	return c.ticker
+}
+
+type tickedTicker struct {
+	c       chan time.Time
+	stopped bool
+}
+
+func (t *tickedTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *tickedTicker) Reset(d time.Duration) {}
+
+func (t *tickedTicker) Stop() {
+	t.stopped = true
+}
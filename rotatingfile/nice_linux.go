@@ -0,0 +1,7 @@
+package rotatingfile
+
+import "syscall"
+
+func setThreadNiceness(niceness int) error {
+	return syscall.Setpriority(syscall.PRIO_PROCESS, syscall.Gettid(), niceness)
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package rotatingfile
+
+// setThreadNiceness is only supported on linux, where each thread has its own niceness
+func setThreadNiceness(niceness int) error {
+	return nil
+}
@@ -0,0 +1,80 @@
+package rotatingfile
+
+import (
+	"runtime"
+	"sync"
+)
+
+const defaultQueueSize = 64
+
+// workerQueue runs the compression and removal jobs out of the rotation goroutine
+type workerQueue struct {
+	jobs        chan func()
+	wg          sync.WaitGroup
+	mux         sync.Mutex
+	idle        *sync.Cond
+	pending     int
+	concurrency int
+	niceness    int
+}
+
+func newWorkerQueue(concurrency, niceness int) *workerQueue {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	q := workerQueue{
+		jobs:        make(chan func(), defaultQueueSize),
+		concurrency: concurrency,
+		niceness:    niceness,
+	}
+	q.idle = sync.NewCond(&q.mux)
+	return &q
+}
+
+func (q *workerQueue) start() {
+	q.wg.Add(q.concurrency)
+	for i := 0; i < q.concurrency; i++ {
+		go q.work()
+	}
+}
+
+func (q *workerQueue) work() {
+	defer q.wg.Done()
+	if q.niceness != 0 {
+		// the thread is never unlocked, so it is discarded with its niceness when the worker exits
+		runtime.LockOSThread()
+		setThreadNiceness(q.niceness)
+	}
+	for job := range q.jobs {
+		job()
+		q.mux.Lock()
+		q.pending--
+		if q.pending == 0 {
+			q.idle.Broadcast()
+		}
+		q.mux.Unlock()
+	}
+}
+
+// enqueue blocks while the queue is full
+func (q *workerQueue) enqueue(job func()) {
+	q.mux.Lock()
+	q.pending++
+	q.mux.Unlock()
+	q.jobs <- job
+}
+
+// waitIdle blocks until there are no pending jobs
+func (q *workerQueue) waitIdle() {
+	q.mux.Lock()
+	for q.pending > 0 {
+		q.idle.Wait()
+	}
+	q.mux.Unlock()
+}
+
+// stop waits until the pending jobs are done
+func (q *workerQueue) stop() {
+	close(q.jobs)
+	q.wg.Wait()
+}
@@ -0,0 +1,37 @@
+package rotatingfile
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+func TestWorkerQueueRunsAllJobsBeforeStopping(t *testing.T) {
+	for _, concurrency := range []int{0, 1, 4} {
+		q := newWorkerQueue(concurrency, 0)
+		q.start()
+		var done int32
+		totalJobs := defaultQueueSize * 3
+		for i := 0; i < totalJobs; i++ {
+			q.enqueue(func() {
+				atomic.AddInt32(&done, 1)
+			})
+		}
+		q.stop()
+		if int(done) != totalJobs {
+			t.Fatalf("Expected %d jobs done with concurrency %d, but %d", totalJobs, concurrency, done)
+		}
+	}
+}
+
+func TestWorkerQueueWithNiceness(t *testing.T) {
+	q := newWorkerQueue(1, 5)
+	q.start()
+	done := false
+	q.enqueue(func() {
+		done = true
+	})
+	q.stop()
+	if !done {
+		t.Fatal("Job was not done")
+	}
+}
@@ -0,0 +1,149 @@
+// Package rotatingtest provides a fake clock and a harness to test the rotation of a rotatingfile.Writer
+// deterministically, without waiting for the real time to pass
+package rotatingtest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Murilovisque/logs/v3/rotatingfile"
+)
+
+// FakeClock is a rotatingfile.Clock whose time only moves when Advance is called
+type FakeClock struct {
+	mux     sync.Mutex
+	changed *sync.Cond
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+// NewFakeClock returns a FakeClock stopped at start
+func NewFakeClock(start time.Time) *FakeClock {
+	c := FakeClock{now: start}
+	c.changed = sync.NewCond(&c.mux)
+	return &c
+}
+
+// Now returns the current fake time
+func (c *FakeClock) Now() time.Time {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.now
+}
+
+// NewTicker returns a ticker firing when the fake time reaches each period
+func (c *FakeClock) NewTicker(d time.Duration) rotatingfile.Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	t := fakeTicker{
+		clock:  c,
+		c:      make(chan time.Time, 1),
+		ack:    make(chan struct{}, 1),
+		period: d,
+		next:   c.now.Add(d),
+	}
+	c.tickers = append(c.tickers, &t)
+	c.changed.Broadcast()
+	return &t
+}
+
+// WaitForTickers blocks until n tickers are active
+func (c *FakeClock) WaitForTickers(n int) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	for c.activeTickers() < n {
+		c.changed.Wait()
+	}
+}
+
+// Advance moves the fake time forward, firing the due tickers in chronological order. After each tick it waits
+// for the receiver to reset or stop the ticker, as the rotatingfile.Writer does once it has rotated
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mux.Lock()
+	target := c.now.Add(d)
+	for {
+		t := c.nextDueTicker(target)
+		if t == nil {
+			break
+		}
+		c.now = t.next
+		t.next = t.next.Add(t.period)
+		select {
+		case <-t.ack:
+		default:
+		}
+		c.mux.Unlock()
+		select {
+		case t.c <- c.Now():
+			<-t.ack
+		default:
+		}
+		c.mux.Lock()
+	}
+	c.now = target
+	c.mux.Unlock()
+}
+
+func (c *FakeClock) activeTickers() int {
+	n := 0
+	for _, t := range c.tickers {
+		if !t.stopped {
+			n++
+		}
+	}
+	return n
+}
+
+func (c *FakeClock) nextDueTicker(target time.Time) *fakeTicker {
+	var due *fakeTicker
+	for _, t := range c.tickers {
+		if t.stopped || t.next.After(target) {
+			continue
+		}
+		if due == nil || t.next.Before(due.next) {
+			due = t
+		}
+	}
+	return due
+}
+
+type fakeTicker struct {
+	clock   *FakeClock
+	c       chan time.Time
+	ack     chan struct{}
+	period  time.Duration
+	next    time.Time
+	stopped bool
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Reset(d time.Duration) {
+	t.clock.mux.Lock()
+	t.period = d
+	t.next = t.clock.now.Add(d)
+	t.stopped = false
+	t.clock.changed.Broadcast()
+	t.clock.mux.Unlock()
+	t.acknowledge()
+}
+
+func (t *fakeTicker) Stop() {
+	t.clock.mux.Lock()
+	t.stopped = true
+	t.clock.changed.Broadcast()
+	t.clock.mux.Unlock()
+	t.acknowledge()
+}
+
+func (t *fakeTicker) acknowledge() {
+	select {
+	case t.ack <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,152 @@
+package rotatingtest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Murilovisque/logs/v3/rotatingfile"
+)
+
+// Harness runs a rotatingfile.Writer in a temporary directory driven by a FakeClock
+type Harness struct {
+	t        testing.TB
+	Dir      string
+	Filename string
+	Clock    *FakeClock
+	Writer   *rotatingfile.Writer
+}
+
+// NewHarness starts a writer of the file app.log inside a temporary directory at the start time. The relative
+// FilenameTemplate, ArchiveDir and Symlink of opts are taken as relative to that directory
+func NewHarness(t testing.TB, start time.Time, opts rotatingfile.Options) *Harness {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "rotatingtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := Harness{t: t, Dir: dir, Filename: filepath.Join(dir, "app.log"), Clock: NewFakeClock(start)}
+	opts.FilenameTemplate = h.path(opts.FilenameTemplate)
+	opts.ArchiveDir = h.path(opts.ArchiveDir)
+	opts.Symlink = h.path(opts.Symlink)
+	opts.Clock = h.Clock
+	h.Writer, err = rotatingfile.New(h.Filename, opts)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	h.Writer.WaitIdle()
+	return &h
+}
+
+// Advance moves the time forward and waits for the resulting rotations, compressions and removals
+func (h *Harness) Advance(d time.Duration) {
+	h.Clock.Advance(d)
+	h.Writer.WaitIdle()
+}
+
+// Rotate forces a rotation and waits for the resulting compressions and removals
+func (h *Harness) Rotate() {
+	h.t.Helper()
+	if err := h.Writer.Rotate(); err != nil {
+		h.t.Fatal(err)
+	}
+	h.Writer.WaitIdle()
+}
+
+// Write writes s to the current file
+func (h *Harness) Write(s string) {
+	h.t.Helper()
+	if _, err := h.Writer.Write([]byte(s)); err != nil {
+		h.t.Fatal(err)
+	}
+}
+
+// Files returns the sorted paths of the files inside the directory, relative to it
+func (h *Harness) Files() []string {
+	h.t.Helper()
+	var files []string
+	err := filepath.Walk(h.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(h.Dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// AssertFiles fails the test unless the directory holds exactly the expected files
+func (h *Harness) AssertFiles(expected ...string) {
+	h.t.Helper()
+	sort.Strings(expected)
+	files := h.Files()
+	if len(files) != len(expected) {
+		h.t.Fatalf("Expected files %v, got %v", expected, files)
+	}
+	for i := range files {
+		if files[i] != expected[i] {
+			h.t.Fatalf("Expected files %v, got %v", expected, files)
+		}
+	}
+}
+
+// AssertExists fails the test if any of the files doesn't exist
+func (h *Harness) AssertExists(names ...string) {
+	h.t.Helper()
+	for _, name := range names {
+		if _, err := os.Lstat(h.path(name)); err != nil {
+			h.t.Fatalf("Expected file %s to exist, files %v", name, h.Files())
+		}
+	}
+}
+
+// AssertNotExists fails the test if any of the files exists
+func (h *Harness) AssertNotExists(names ...string) {
+	h.t.Helper()
+	for _, name := range names {
+		if _, err := os.Lstat(h.path(name)); err == nil {
+			h.t.Fatalf("Expected file %s to not exist, files %v", name, h.Files())
+		}
+	}
+}
+
+// ReadFile returns the content of the file
+func (h *Harness) ReadFile(name string) string {
+	h.t.Helper()
+	b, err := ioutil.ReadFile(h.path(name))
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	return string(b)
+}
+
+// Close closes the writer and removes the directory
+func (h *Harness) Close() {
+	h.t.Helper()
+	defer os.RemoveAll(h.Dir)
+	if err := h.Writer.Close(); err != nil {
+		h.t.Fatal(err)
+	}
+}
+
+func (h *Harness) path(name string) string {
+	if name == "" || filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(h.Dir, name)
+}
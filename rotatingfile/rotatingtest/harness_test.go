@@ -0,0 +1,126 @@
+package rotatingtest
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Murilovisque/logs/v3/rotatingfile"
+)
+
+func TestHarnessRotatesCompressesAndRemoves(t *testing.T) {
+	c, err := rotatingfile.NewGzipCompressor(gzip.BestSpeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2026, 10, 17, 23, 30, 0, 0, time.Local)
+	h := NewHarness(t, start, rotatingfile.Options{
+		RotatingScheme:        rotatingfile.PerHour,
+		AmountOfFilesToRetain: 2,
+		Compressor:            c,
+		Symlink:               "current.log",
+	})
+	defer h.Close()
+	h.Write("first\n")
+	h.AssertFiles("app-20261017-23.log", "current.log")
+
+	h.Advance(30 * time.Minute)
+	h.AssertFiles("app-20261017-23.log.gz", "app-20261018-00.log", "current.log")
+	h.Write("second\n")
+	if h.ReadFile("current.log") != "second\n" {
+		t.Fatalf("Expected the symlink pointing to the new file, got %q", h.ReadFile("current.log"))
+	}
+
+	h.Advance(time.Hour)
+	h.AssertFiles("app-20261017-23.log.gz", "app-20261018-00.log.gz", "app-20261018-01.log", "current.log")
+
+	h.Advance(2 * time.Hour)
+	h.AssertNotExists("app-20261017-23.log.gz", "app-20261018-00.log.gz")
+	h.AssertExists("app-20261018-01.log.gz", "app-20261018-02.log.gz", "app-20261018-03.log")
+}
+
+func TestHarnessManualRotation(t *testing.T) {
+	h := NewHarness(t, time.Date(2026, 10, 17, 10, 0, 0, 0, time.Local), rotatingfile.Options{
+		RotatingScheme:        rotatingfile.PerDay,
+		AmountOfFilesToRetain: 1,
+	})
+	defer h.Close()
+	h.Write("before\n")
+	h.Rotate()
+	h.Write("after\n")
+	h.AssertFiles("app-20261017.log", "app-20261017.1.log")
+	if h.ReadFile("app-20261017.log") != "before\n" || h.ReadFile("app-20261017.1.log") != "after\n" {
+		t.Fatal("Expected each write in its own file")
+	}
+	h.Advance(48 * time.Hour)
+	h.AssertFiles("app-20261018.log", "app-20261019.log")
+}
+
+func TestHarnessFlushesPeriodically(t *testing.T) {
+	h := NewHarness(t, time.Date(2026, 10, 17, 10, 0, 0, 0, time.Local), rotatingfile.Options{
+		RotatingScheme: rotatingfile.PerDay,
+		BufferSize:     4096,
+		FlushInterval:  time.Second,
+		SyncPolicy:     rotatingfile.SyncPeriodically,
+		SyncInterval:   time.Minute,
+	})
+	defer h.Close()
+	h.Write("buffered\n")
+	if content := h.ReadFile("app-20261017.log"); content != "" {
+		t.Fatalf("Expected the entry still buffered, but found %q", content)
+	}
+	h.Advance(time.Second)
+	if content := h.ReadFile("app-20261017.log"); content != "buffered\n" {
+		t.Fatalf("Expected the entry flushed, but found %q", content)
+	}
+}
+
+func TestHarnessTieredRetention(t *testing.T) {
+	c, err := rotatingfile.NewGzipCompressor(gzip.BestSpeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHarness(t, time.Date(2026, 10, 17, 22, 0, 0, 0, time.Local), rotatingfile.Options{
+		RotatingScheme:        rotatingfile.PerHour,
+		AmountOfFilesToRetain: 2,
+		DailyArchives:         1,
+		Compressor:            c,
+	})
+	defer h.Close()
+	h.Write("22a\n")
+	h.Rotate()
+	h.Write("22b\n")
+	h.Advance(time.Hour)
+	h.Write("23\n")
+	h.Advance(time.Hour)
+	h.Write("00\n")
+	h.AssertFiles("app-20261017-22.log.gz", "app-20261017-22.1.log.gz", "app-20261017-23.log.gz", "app-20261018-00.log")
+
+	// the day 17 is kept until all its hours are beyond the retention
+	h.Advance(time.Hour)
+	h.AssertExists("app-20261017-22.log.gz", "app-20261017-23.log.gz")
+	h.Advance(time.Hour)
+	h.AssertFiles("app-20261017.log.gz", "app-20261018-00.log.gz", "app-20261018-01.log.gz", "app-20261018-02.log")
+	if content := readGzip(t, h, "app-20261017.log.gz"); content != "22a\n22b\n23\n" {
+		t.Fatalf("Expected the hourly files merged in order, but found %q", content)
+	}
+
+	h.Advance(24 * time.Hour)
+	h.AssertNotExists("app-20261017.log.gz")
+	h.AssertExists("app-20261018.log.gz")
+}
+
+func readGzip(t *testing.T, h *Harness, name string) string {
+	t.Helper()
+	reader, err := gzip.NewReader(strings.NewReader(h.ReadFile(name)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
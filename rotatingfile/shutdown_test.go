@@ -0,0 +1,90 @@
+package rotatingfile
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShutdownRespectsContextAndIsIdempotent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	w, err := New(filepath.Join(dir, "teste.log"), Options{RotatingScheme: PerDay, BufferSize: 4096})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("buffered\n")); err != nil {
+		t.Fatal(err)
+	}
+	release := make(chan struct{})
+	w.queue.enqueue(func() {
+		<-release
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := w.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Expected %v, but received %v", context.DeadlineExceeded, err)
+	}
+	close(release)
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- w.Shutdown(context.Background())
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != ErrClosed {
+			t.Fatalf("Expected %v, but received %v", ErrClosed, err)
+		}
+	}
+	if err := w.Close(); err != ErrClosed {
+		t.Fatalf("Expected %v, but received %v", ErrClosed, err)
+	}
+	assertContent(t, w.Filename(), "buffered\n")
+}
+
+func TestConcurrentCloseDoesNotBlock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	w, err := New(filepath.Join(dir, "teste.log"), Options{RotatingScheme: PerDay})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- w.Close()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	closed := 0
+	for err := range errs {
+		if err == nil {
+			closed++
+		} else if err != ErrClosed {
+			t.Fatal(err)
+		}
+	}
+	if closed != 1 {
+		t.Fatalf("Expected a single Close to close the writer, but %d did", closed)
+	}
+}
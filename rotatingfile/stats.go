@@ -0,0 +1,120 @@
+package rotatingfile
+
+import (
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/Murilovisque/logs/v3/internal/compressor"
+)
+
+// Stats is a snapshot of the counters and gauges of a Writer
+type Stats struct {
+	BytesWritten uint64
+	WriteErrors  uint64
+	Rotations    uint64
+	// FilesRemoved by the retention and the disk guard
+	FilesRemoved uint64
+	// FilesCompressed, CompressionDuration, UncompressedBytes and CompressedBytes are the totals of the compressions
+	FilesCompressed     uint64
+	CompressionDuration time.Duration
+	UncompressedBytes   uint64
+	CompressedBytes     uint64
+	// CurrentFileSize is the size of the file being written, including the buffered bytes
+	CurrentFileSize int64
+	// HeldFiles are the files older than the retention kept only because of a legal hold, as found by the last
+	// retention pass
+	HeldFiles []string
+}
+
+// counters of the Writer, a nil counters counts nothing. Only uint64 fields, so they are aligned for the atomic
+// operations
+type counters struct {
+	bytesWritten      uint64
+	writeErrors       uint64
+	rotations         uint64
+	filesRemoved      uint64
+	filesCompressed   uint64
+	compressionNanos  uint64
+	uncompressedBytes uint64
+	compressedBytes   uint64
+}
+
+func (c *counters) countWrite(n int, err error) {
+	if c == nil {
+		return
+	}
+	if n > 0 {
+		atomic.AddUint64(&c.bytesWritten, uint64(n))
+	}
+	if err != nil && err != ErrClosed && err != ErrDiskFull {
+		atomic.AddUint64(&c.writeErrors, 1)
+	}
+}
+
+func (c *counters) countRotation() {
+	if c != nil {
+		atomic.AddUint64(&c.rotations, 1)
+	}
+}
+
+func (c *counters) countRemoval() {
+	if c != nil {
+		atomic.AddUint64(&c.filesRemoved, 1)
+	}
+}
+
+func (c *counters) countCompression(d time.Duration, uncompressed, compressed int64) {
+	if c == nil {
+		return
+	}
+	atomic.AddUint64(&c.filesCompressed, 1)
+	atomic.AddUint64(&c.compressionNanos, uint64(d))
+	atomic.AddUint64(&c.uncompressedBytes, uint64(uncompressed))
+	atomic.AddUint64(&c.compressedBytes, uint64(compressed))
+}
+
+// Stats returns the counters since the writer was opened, the size of the current file and the held files found by
+// the last retention pass
+func (w *Writer) Stats() Stats {
+	var s Stats
+	if c := w.counters; c != nil {
+		s.BytesWritten = atomic.LoadUint64(&c.bytesWritten)
+		s.WriteErrors = atomic.LoadUint64(&c.writeErrors)
+		s.Rotations = atomic.LoadUint64(&c.rotations)
+		s.FilesRemoved = atomic.LoadUint64(&c.filesRemoved)
+		s.FilesCompressed = atomic.LoadUint64(&c.filesCompressed)
+		s.CompressionDuration = time.Duration(atomic.LoadUint64(&c.compressionNanos))
+		s.UncompressedBytes = atomic.LoadUint64(&c.uncompressedBytes)
+		s.CompressedBytes = atomic.LoadUint64(&c.compressedBytes)
+	}
+	w.mux.Lock()
+	if !w.closed && w.file != nil {
+		if info, err := w.file.Stat(); err == nil {
+			s.CurrentFileSize = info.Size()
+		}
+		if w.buf != nil {
+			s.CurrentFileSize += int64(w.buf.Buffered())
+		}
+	}
+	w.mux.Unlock()
+	s.HeldFiles = w.HeldFiles()
+	return s
+}
+
+// compress compresses the file with c, counting the duration and the sizes
+func compress(c compressor.Compressor, filename string, w *Writer) error {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	if err := compressor.CompressFile(c, filename, w.permissions); err != nil {
+		return err
+	}
+	duration := time.Since(start)
+	if compressed, err := os.Stat(filename + c.Extension()); err == nil {
+		w.counters.countCompression(duration, info.Size(), compressed.Size())
+	}
+	return nil
+}
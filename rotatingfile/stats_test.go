@@ -0,0 +1,43 @@
+package rotatingfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriterStats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "teste.log")
+	oldFile := filepath.Join(dir, "teste-20121206.log.zip")
+	if err := ioutil.WriteFile(oldFile, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w, err := New(filename, Options{RotatingScheme: PerDay, AmountOfFilesToRetain: 1, Compressor: NewZipCompressor(), BufferSize: 4096})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if _, err := w.Write([]byte("teste\n")); err != nil {
+		t.Fatal(err)
+	}
+	if s := w.Stats(); s.BytesWritten != 6 || s.CurrentFileSize != 6 {
+		t.Fatalf("Unexpected stats %+v", s)
+	}
+	if err := w.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	w.WaitIdle()
+	s := w.Stats()
+	if s.Rotations != 1 || s.FilesRemoved != 1 || s.FilesCompressed != 1 || s.CurrentFileSize != 0 {
+		t.Fatalf("Unexpected stats %+v", s)
+	}
+	if s.UncompressedBytes != 6 || s.CompressedBytes == 0 || s.CompressionDuration <= 0 {
+		t.Fatalf("Unexpected compression stats %+v", s)
+	}
+}
@@ -0,0 +1,74 @@
+package rotatingfile
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/Murilovisque/logs/v3/internal/compressor"
+)
+
+var (
+	ErrInvalidStreamCompressionLevel = errors.New("invalid stream compression level")
+	ErrStreamCompressionMultiProcess = errors.New("stream compression can't be used by multiple processes")
+)
+
+// activeFilename returns the name of the file written for filename, compressed when streaming
+func (w *Writer) activeFilename(filename string) string {
+	if !w.streamCompression {
+		return filename
+	}
+	return filename + compressor.GzipExtension
+}
+
+// openActiveFile opens the file, starts its compression stream when streaming and writes its header
+func openActiveFile(filename, previousFilename string, w *Writer) (*os.File, *gzip.Writer, error) {
+	f, err := openFile(filename, w)
+	if err != nil {
+		return nil, nil, err
+	}
+	var stream *gzip.Writer
+	var out io.Writer = f
+	if w.streamCompression {
+		if stream, err = gzip.NewWriterLevel(f, w.streamCompressionLevel); err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		out = stream
+	}
+	if err := prepareFile(f, out, previousFilename, w); err != nil {
+		return f, stream, err
+	}
+	if stream != nil {
+		// the header is readable even if the process dies before the first flush
+		return f, stream, stream.Flush()
+	}
+	return f, stream, nil
+}
+
+// sink returns where the entries are written: the compression stream of the file or the file itself
+func (w *Writer) sink() io.Writer {
+	if w.stream != nil {
+		return w.stream
+	}
+	return w.file
+}
+
+// closeFile flushes the buffer, finalizes the compression stream, fsyncs and closes the file, must be called
+// holding mux
+func (w *Writer) closeFile() error {
+	err := w.flushFile()
+	if w.stream != nil {
+		if closeErr := w.stream.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if syncErr := w.file.Sync(); err == nil {
+		err = syncErr
+	}
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,103 @@
+package rotatingfile
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Murilovisque/logs/v3/internal/compressor"
+)
+
+func TestStreamCompression(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "teste.log")
+	var rotated []RotatedFile
+	w, err := New(filename, Options{RotatingScheme: PerDay, StreamCompression: true, FlushInterval: time.Hour, Header: true,
+		PostRotateHooks: []PostRotateHook{func(f RotatedFile) {
+			rotated = append(rotated, f)
+		}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := w.Filename()
+	if first != buildFilenameWithTimeExtension(time.Now(), filename, PerDay)+compressor.GzipExtension {
+		t.Fatalf("Unexpected current file %s", first)
+	}
+	if _, err := w.Write([]byte("first\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	// the stream isn't finished, but it is readable up to the flush
+	content, err := readGzip(first)
+	if err != io.ErrUnexpectedEOF || !strings.HasPrefix(content, "# created: ") || !strings.HasSuffix(content, "first\n") {
+		t.Fatalf("Expected the flushed content of an unfinished stream, but found %q and %v", content, err)
+	}
+	if err := w.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("second\n")); err != nil {
+		t.Fatal(err)
+	}
+	second := w.Filename()
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for f, expected := range map[string]string{first: "first\n", second: "second\n"} {
+		content, err := readGzip(f)
+		if err != nil || !strings.HasSuffix(content, expected) {
+			t.Fatalf("Expected a finished stream ending with %q in %s, but found %q and %v", expected, f, content, err)
+		}
+	}
+	if len(rotated) != 1 || rotated[0].Path != first || !rotated[0].Compressed {
+		t.Fatalf("Unexpected rotated files %+v", rotated)
+	}
+
+	// a restarted writer starts a new stream instead of appending to the previous one
+	w, err = New(filename, Options{RotatingScheme: PerDay, StreamCompression: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if w.Filename() != buildFilenameWithSequence(time.Now(), filename, PerDay, 2)+compressor.GzipExtension {
+		t.Fatalf("Expected a new stream, but received %s", w.Filename())
+	}
+}
+
+func TestOpenWithInvalidStreamCompressionOptions(t *testing.T) {
+	for _, tt := range []struct {
+		opts     Options
+		expected error
+	}{
+		{Options{StreamCompression: true, StreamCompressionLevel: gzip.BestCompression + 1}, ErrInvalidStreamCompressionLevel},
+		{Options{StreamCompression: true, MultiProcess: true}, ErrStreamCompressionMultiProcess},
+	} {
+		if _, err := Open("teste.log", tt.opts); err != tt.expected {
+			t.Fatalf("Expected %v, but received %v", tt.expected, err)
+		}
+	}
+}
+
+func readGzip(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	reader, err := gzip.NewReader(f)
+	if err != nil {
+		return "", err
+	}
+	content, err := ioutil.ReadAll(reader)
+	return string(content), err
+}
@@ -0,0 +1,46 @@
+package rotatingfile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+const symlinkTempExtension = ".link.tmp"
+
+var (
+	ErrSymlinkIsNotSymlink = errors.New("symlink path exists and is not a symlink")
+)
+
+func checkSymlink(linkName string) error {
+	info, err := os.Lstat(linkName)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		return ErrSymlinkIsNotSymlink
+	}
+	return nil
+}
+
+// updateSymlink points linkName to target by renaming a new symlink over it, so readers never see it missing
+func updateSymlink(linkName, target string) error {
+	relativeTarget, err := filepath.Rel(filepath.Dir(linkName), target)
+	if err != nil {
+		relativeTarget = target
+	}
+	tempLinkName := linkName + symlinkTempExtension
+	os.Remove(tempLinkName)
+	err = os.Symlink(relativeTarget, tempLinkName)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tempLinkName, linkName)
+	if err != nil {
+		os.Remove(tempLinkName)
+	}
+	return err
+}
@@ -0,0 +1,63 @@
+package rotatingfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUpdateSymlink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	linkName := filepath.Join(dir, "teste.log")
+	for _, target := range []string{"teste-20121206.log", "teste-20121207.log"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, target), []byte(target), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := updateSymlink(linkName, filepath.Join(dir, target)); err != nil {
+			t.Fatal(err)
+		}
+		linkTarget, err := os.Readlink(linkName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if linkTarget != target {
+			t.Fatalf("Expected %s, but received %s", target, linkTarget)
+		}
+		content, err := ioutil.ReadFile(linkName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != target {
+			t.Fatalf("Expected %s, but received %s", target, content)
+		}
+	}
+	if _, err := os.Lstat(linkName + symlinkTempExtension); !os.IsNotExist(err) {
+		t.Fatalf("Temporary symlink should not exist, but stat returned %v", err)
+	}
+	if err := checkSymlink(linkName); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckSymlinkWithRegularFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	linkName := filepath.Join(dir, "teste.log")
+	if err := checkSymlink(linkName); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(linkName, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkSymlink(linkName); err != ErrSymlinkIsNotSymlink {
+		t.Fatalf("Expected %v, but received %v", ErrSymlinkIsNotSymlink, err)
+	}
+}
@@ -0,0 +1,129 @@
+package rotatingfile
+
+import (
+	"bufio"
+	"errors"
+	"time"
+)
+
+// SyncPolicy defines when the written data is fsynced to the disk, besides at rotation and close
+type SyncPolicy int
+
+const (
+	// SyncNever leaves the fsync to the operating system
+	SyncNever SyncPolicy = iota
+	// SyncPeriodically fsyncs every Options.SyncInterval
+	SyncPeriodically
+	// SyncOnError fsyncs when Sync is called, which the loggers do after each ERROR or FATAL entry
+	SyncOnError
+	// SyncEveryWrite fsyncs after each write
+	SyncEveryWrite
+)
+
+const (
+	defaultFlushInterval = time.Second
+	defaultSyncInterval  = time.Second
+)
+
+var (
+	ErrInvalidBufferSize    = errors.New("buffer size is less than zero")
+	ErrInvalidFlushInterval = errors.New("flush interval is less than zero")
+	ErrInvalidSyncInterval  = errors.New("sync interval is less than zero")
+)
+
+// Sync writes the buffered data to the file and fsyncs it
+func (w *Writer) Sync() error {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	if w.closed {
+		return ErrClosed
+	}
+	return w.syncFile()
+}
+
+// flushFile writes the buffered data to the file, up to a flush point of the compression stream, must be called
+// holding mux
+func (w *Writer) flushFile() error {
+	if w.buf != nil {
+		if err := w.buf.Flush(); err != nil {
+			return err
+		}
+	}
+	if w.stream != nil {
+		return w.stream.Flush()
+	}
+	return nil
+}
+
+// syncFile flushes and fsyncs the file, must be called holding mux
+func (w *Writer) syncFile() error {
+	if err := w.flushFile(); err != nil {
+		return err
+	}
+	return w.file.Sync()
+}
+
+// newBuffer returns nil when the writes are not buffered
+func newBuffer(w *Writer) *bufio.Writer {
+	if w.bufferSize == 0 {
+		return nil
+	}
+	return bufio.NewWriterSize(w.sink(), w.bufferSize)
+}
+
+// mustFlushPeriodically tells if the flushing goroutine is needed
+func (w *Writer) mustFlushPeriodically() bool {
+	return w.flushesPeriodically() || w.syncPolicy == SyncPeriodically
+}
+
+// flushesPeriodically tells if there is a buffer or a compression stream to flush
+func (w *Writer) flushesPeriodically() bool {
+	return w.bufferSize > 0 || w.streamCompression
+}
+
+// flushTickers creates the tickers of flushing, nil when the buffer is not flushed or the file not fsynced
+// periodically
+func (w *Writer) flushTickers() (flushTick, syncTick Ticker) {
+	if w.flushesPeriodically() {
+		flushTick = w.clock.NewTicker(w.flushInterval)
+	}
+	if w.syncPolicy == SyncPeriodically {
+		syncTick = w.clock.NewTicker(w.syncInterval)
+	}
+	return flushTick, syncTick
+}
+
+// flushing flushes the buffer and fsyncs the file periodically, according to the policy. The tickers are
+// reset after each operation, as rotatingFile does
+func flushing(w *Writer, flushTick, syncTick Ticker) {
+	defer w.background.Done()
+	var flushC, syncC <-chan time.Time
+	if flushTick != nil {
+		defer flushTick.Stop()
+		flushC = flushTick.C()
+	}
+	if syncTick != nil {
+		defer syncTick.Stop()
+		syncC = syncTick.C()
+	}
+	for {
+		select {
+		case <-flushC:
+			w.mux.Lock()
+			if err := w.flushFile(); err != nil {
+				w.reportError(OpWrite, w.currentLogFilename, err)
+			}
+			w.mux.Unlock()
+			flushTick.Reset(w.flushInterval)
+		case <-syncC:
+			w.mux.Lock()
+			if err := w.syncFile(); err != nil {
+				w.reportError(OpWrite, w.currentLogFilename, err)
+			}
+			w.mux.Unlock()
+			syncTick.Reset(w.syncInterval)
+		case <-w.stopBackground:
+			return
+		}
+	}
+}
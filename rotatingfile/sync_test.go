@@ -0,0 +1,91 @@
+package rotatingfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBufferedWrites(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	w, err := New(filepath.Join(dir, "teste.log"), Options{RotatingScheme: PerDay, BufferSize: 4096, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := w.Filename()
+	if _, err := w.Write([]byte("first\n")); err != nil {
+		t.Fatal(err)
+	}
+	assertContent(t, filename, "")
+	if err := w.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	assertContent(t, filename, "first\n")
+	if _, err := w.Write([]byte("second\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	assertContent(t, filename, "first\nsecond\n")
+	if _, err := w.Write([]byte("third\n")); err != nil {
+		t.Fatal(err)
+	}
+	rotated := w.Filename()
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	assertContent(t, rotated, "third\n")
+	if err := w.Sync(); err != ErrClosed {
+		t.Fatalf("Expected %v, but received %v", ErrClosed, err)
+	}
+}
+
+func TestSyncEveryWriteWithBuffer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	w, err := New(filepath.Join(dir, "teste.log"), Options{RotatingScheme: PerDay, BufferSize: 4096, SyncPolicy: SyncEveryWrite})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if _, err := w.Write([]byte("first\n")); err != nil {
+		t.Fatal(err)
+	}
+	assertContent(t, w.Filename(), "first\n")
+}
+
+func TestOpenWithInvalidBufferOptions(t *testing.T) {
+	for _, tt := range []struct {
+		opts     Options
+		expected error
+	}{
+		{Options{BufferSize: -1}, ErrInvalidBufferSize},
+		{Options{FlushInterval: -1}, ErrInvalidFlushInterval},
+		{Options{SyncInterval: -1}, ErrInvalidSyncInterval},
+	} {
+		if _, err := Open("teste.log", tt.opts); err != tt.expected {
+			t.Fatalf("Expected %v, but received %v", tt.expected, err)
+		}
+	}
+}
+
+func assertContent(t *testing.T, filename, expected string) {
+	t.Helper()
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != expected {
+		t.Fatalf("Expected %q in %s, but found %q", expected, filename, content)
+	}
+}
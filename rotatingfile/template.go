@@ -0,0 +1,263 @@
+package rotatingfile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	tokenYear     = "%Y"
+	tokenMonth    = "%m"
+	tokenDay      = "%d"
+	tokenHour     = "%H"
+	tokenSequence = "{seq}"
+	tokenHostname = "{hostname}"
+)
+
+var (
+	ErrInvalidFilenameTemplate = errors.New("invalid filename template")
+	templateTokens             = []string{tokenYear, tokenMonth, tokenDay, tokenHour, tokenSequence, tokenHostname}
+)
+
+type templatePart struct {
+	literal string
+	token   string
+}
+
+// filenameTemplate names the rotated files and recognises them during retention. The tokens are %Y, %m, %d and %H
+// for the period, {seq} for the sequence of files rotated within the same period, rendered as ".N" when greater
+// than zero, and {hostname}. %% is a literal %
+type filenameTemplate struct {
+	parts    []templatePart
+	glob     string
+	hostname string
+}
+
+func parseFilenameTemplate(template string, rotatingScheme TimeRotatingScheme) (*filenameTemplate, error) {
+	ft := filenameTemplate{}
+	literal := strings.Builder{}
+	for i := 0; i < len(template); {
+		if strings.HasPrefix(template[i:], "%%") {
+			literal.WriteByte('%')
+			i += 2
+			continue
+		}
+		token := ""
+		for _, t := range templateTokens {
+			if strings.HasPrefix(template[i:], t) {
+				token = t
+				break
+			}
+		}
+		if token == "" {
+			if template[i] == '%' {
+				return nil, ErrInvalidFilenameTemplate
+			}
+			literal.WriteByte(template[i])
+			i++
+			continue
+		}
+		if literal.Len() > 0 {
+			ft.parts = append(ft.parts, templatePart{literal: literal.String()})
+			literal.Reset()
+		}
+		ft.parts = append(ft.parts, templatePart{token: token})
+		i += len(token)
+	}
+	if literal.Len() > 0 {
+		ft.parts = append(ft.parts, templatePart{literal: literal.String()})
+	}
+	if !ft.hasToken(tokenSequence) {
+		ft.parts = append(ft.parts, templatePart{token: tokenSequence})
+	}
+	for _, t := range rotatingScheme.requiredTokens() {
+		if !ft.hasToken(t) {
+			return nil, ErrInvalidFilenameTemplate
+		}
+	}
+	if ft.hasToken(tokenHostname) {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, err
+		}
+		ft.hostname = hostname
+	}
+	ft.glob = ft.buildGlob()
+	return &ft, nil
+}
+
+// defaultFilenameTemplate is <filename without ext>-<period>{seq}<ext>, e.g. app-20261017.log
+func defaultFilenameTemplate(filename string, rotatingScheme TimeRotatingScheme) *filenameTemplate {
+	filenameExt := getFilenameExt(filename, true)
+	ft := filenameTemplate{glob: getFilenameGlobWithoutExt(filename)}
+	ft.parts = append(ft.parts, templatePart{literal: filename[:len(filename)-len(filenameExt)] + "-"})
+	for _, t := range rotatingScheme.requiredTokens() {
+		if t == tokenHour {
+			ft.parts = append(ft.parts, templatePart{literal: "-"})
+		}
+		ft.parts = append(ft.parts, templatePart{token: t})
+	}
+	ft.parts = append(ft.parts, templatePart{token: tokenSequence})
+	if filenameExt != "" {
+		ft.parts = append(ft.parts, templatePart{literal: filenameExt})
+	}
+	return &ft
+}
+
+func (ft *filenameTemplate) hasToken(token string) bool {
+	for _, p := range ft.parts {
+		if p.token == token {
+			return true
+		}
+	}
+	return false
+}
+
+func (ft *filenameTemplate) format(moment time.Time, sequence int) string {
+	builder := strings.Builder{}
+	for _, p := range ft.parts {
+		switch p.token {
+		case "":
+			builder.WriteString(p.literal)
+		case tokenYear:
+			builder.WriteString(moment.Format("2006"))
+		case tokenMonth:
+			builder.WriteString(moment.Format("01"))
+		case tokenDay:
+			builder.WriteString(moment.Format("02"))
+		case tokenHour:
+			builder.WriteString(moment.Format("15"))
+		case tokenSequence:
+			if sequence > 0 {
+				builder.WriteString(".")
+				builder.WriteString(strconv.Itoa(sequence))
+			}
+		case tokenHostname:
+			builder.WriteString(ft.hostname)
+		}
+	}
+	return builder.String()
+}
+
+// buildGlob matches every file of the template, compressed or not
+func (ft *filenameTemplate) buildGlob() string {
+	builder := strings.Builder{}
+	for _, p := range ft.parts {
+		switch p.token {
+		case "":
+			builder.WriteString(escapeGlob(p.literal))
+		case tokenHostname:
+			builder.WriteString(escapeGlob(ft.hostname))
+		default:
+			builder.WriteString("*")
+		}
+	}
+	builder.WriteString("*")
+	return builder.String()
+}
+
+// staticDir is the directory of the template without tokens, the files are never outside it
+func (ft *filenameTemplate) staticDir() string {
+	if len(ft.parts) == 0 || ft.parts[0].token != "" {
+		return "."
+	}
+	return filepath.Dir(ft.parts[0].literal + "x")
+}
+
+func escapeGlob(s string) string {
+	if filepath.Separator == '\\' {
+		return s
+	}
+	replacer := strings.NewReplacer("\\", "\\\\", "*", "\\*", "?", "\\?", "[", "\\[")
+	return replacer.Replace(s)
+}
+
+// filenameMatcher recognises the files named by a template, optionally compressed
+type filenameMatcher struct {
+	regex         *regexp.Regexp
+	tokenGroups   map[string]int
+	compressGroup int
+}
+
+func (ft *filenameTemplate) matcher(compressExtensionsRegex string) (*filenameMatcher, error) {
+	fm := filenameMatcher{tokenGroups: make(map[string]int)}
+	builder := strings.Builder{}
+	builder.WriteString("^")
+	group := 0
+	for _, p := range ft.parts {
+		switch p.token {
+		case "":
+			builder.WriteString(regexp.QuoteMeta(p.literal))
+			continue
+		case tokenHostname:
+			builder.WriteString(regexp.QuoteMeta(ft.hostname))
+			continue
+		case tokenYear:
+			builder.WriteString("(\\d{4})")
+		case tokenSequence:
+			builder.WriteString("(\\.\\d+)?")
+		default:
+			builder.WriteString("(\\d{2})")
+		}
+		group++
+		if _, ok := fm.tokenGroups[p.token]; !ok {
+			fm.tokenGroups[p.token] = group
+		}
+	}
+	builder.WriteString("(")
+	builder.WriteString(compressExtensionsRegex)
+	builder.WriteString(")?$")
+	fm.compressGroup = group + 1
+	regex, err := regexp.Compile(builder.String())
+	if err != nil {
+		return nil, err
+	}
+	fm.regex = regex
+	return &fm, nil
+}
+
+// match returns the period of the file and its compression extension, empty when it is not compressed
+func (fm *filenameMatcher) match(filename string, loc *time.Location) (time.Time, string, bool) {
+	matchGroups := fm.regex.FindStringSubmatch(filename)
+	if matchGroups == nil {
+		return time.Time{}, "", false
+	}
+	values := map[string]int{tokenMonth: 1, tokenDay: 1}
+	for token, group := range fm.tokenGroups {
+		if token == tokenSequence {
+			continue
+		}
+		v, err := strconv.Atoi(matchGroups[group])
+		if err != nil {
+			return time.Time{}, "", false
+		}
+		values[token] = v
+	}
+	fileTime := time.Date(values[tokenYear], time.Month(values[tokenMonth]), values[tokenDay], values[tokenHour], 0, 0, 0, loc)
+	if fileTime.Month() != time.Month(values[tokenMonth]) || fileTime.Day() != values[tokenDay] || fileTime.Hour() != values[tokenHour] {
+		return time.Time{}, "", false
+	}
+	return fileTime, matchGroups[fm.compressGroup], true
+}
+
+// sequence returns the sequence of a file matched, zero when it has none
+func (fm *filenameMatcher) sequence(filename string) int {
+	group, ok := fm.tokenGroups[tokenSequence]
+	if !ok {
+		return 0
+	}
+	matchGroups := fm.regex.FindStringSubmatch(filename)
+	if matchGroups == nil || matchGroups[group] == "" {
+		return 0
+	}
+	sequence, err := strconv.Atoi(matchGroups[group][1:])
+	if err != nil {
+		return 0
+	}
+	return sequence
+}
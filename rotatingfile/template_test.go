@@ -0,0 +1,126 @@
+package rotatingfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseFilenameTemplate(t *testing.T) {
+	tests := []struct {
+		template       string
+		rotatingScheme TimeRotatingScheme
+		err            error
+	}{
+		{"/var/log/app.log.%Y-%m-%d", PerDay, nil},
+		{"/var/log/%Y/%m/%d/app{seq}.log", PerDay, nil},
+		{"/var/log/app-%Y%m%d-%H.log", PerHour, nil},
+		{"/var/log/app-{hostname}-%Y%m%d%%.log", PerDay, nil},
+		{"/var/log/app-%Y%m.log", PerDay, ErrInvalidFilenameTemplate},
+		{"/var/log/app-%Y%m%d.log", PerHour, ErrInvalidFilenameTemplate},
+		{"/var/log/app-%Y%m%d%x.log", PerDay, ErrInvalidFilenameTemplate},
+	}
+	for _, test := range tests {
+		_, err := parseFilenameTemplate(test.template, test.rotatingScheme)
+		if err != test.err {
+			t.Fatalf("Expected %v for %s, but received %v", test.err, test.template, err)
+		}
+	}
+}
+
+func TestFilenameTemplateFormat(t *testing.T) {
+	now, _ := time.Parse("2006 Jan 02 15:04:05", "2012 Dec 07 06:15:30")
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		template string
+		sequence int
+		exp      string
+		glob     string
+	}{
+		{"/var/log/app.log.%Y-%m-%d", 0, "/var/log/app.log.2012-12-07", "/var/log/app.log.*-*-***"},
+		{"/var/log/app.log.%Y-%m-%d", 2, "/var/log/app.log.2012-12-07.2", "/var/log/app.log.*-*-***"},
+		{"/var/log/%Y/%m/%d/app{seq}.log", 1, "/var/log/2012/12/07/app.1.log", "/var/log/*/*/*/app*.log*"},
+		{"/var/log/app-%Y%m%d-%H{seq}.log", 0, "/var/log/app-20121207-06.log", "/var/log/app-***-**.log*"},
+		{"/var/log/app-{hostname}-%Y%m%d%%.log", 0, "/var/log/app-" + hostname + "-20121207%.log", "/var/log/app-" + hostname + "-***%.log**"},
+	}
+	for _, test := range tests {
+		ft, err := parseFilenameTemplate(test.template, PerDay)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f := ft.format(now, test.sequence); f != test.exp {
+			t.Fatalf("Expected %s, but received %s", test.exp, f)
+		}
+		if ft.glob != test.glob {
+			t.Fatalf("Expected %s, but received %s", test.glob, ft.glob)
+		}
+	}
+}
+
+func TestFilenameMatcher(t *testing.T) {
+	ft, err := parseFilenameTemplate("/var/log/%Y/%m/%d/app{seq}.log", PerDay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	matcher, err := ft.matcher("\\.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		vl          string
+		ok          bool
+		fileTime    time.Time
+		compressExt string
+	}{
+		{"/var/log/2012/12/07/app.log", true, time.Date(2012, 12, 7, 0, 0, 0, 0, time.UTC), ""},
+		{"/var/log/2012/12/07/app.3.log.gz", true, time.Date(2012, 12, 7, 0, 0, 0, 0, time.UTC), ".gz"},
+		{"/var/log/2012/13/07/app.log", false, time.Time{}, ""},
+		{"/var/log/2012/12/07/app.log.zip", false, time.Time{}, ""},
+		{"/var/log/2012/12/07/other.log", false, time.Time{}, ""},
+	}
+	for _, test := range tests {
+		fileTime, compressExt, ok := matcher.match(test.vl, time.UTC)
+		if ok != test.ok || !fileTime.Equal(test.fileTime) || compressExt != test.compressExt {
+			t.Fatalf("Unexpected match of %s: %v %v %s", test.vl, ok, fileTime, compressExt)
+		}
+	}
+}
+
+func TestRotateWithDatePartitionedTemplate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	oldFile := filepath.Join(dir, "2012", "12", "07", "app.log")
+	if err := os.MkdirAll(filepath.Dir(oldFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(oldFile, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w, err := New(filepath.Join(dir, "app.log"), Options{RotatingScheme: PerDay, FilenameTemplate: filepath.Join(dir, "%Y", "%m", "%d", "app{seq}.log")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for _, f := range []string{"app.log", "app.1.log"} {
+		if _, err := os.Stat(filepath.Join(dir, now.Format("2006"), now.Format("01"), now.Format("02"), f)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "2012")); !os.IsNotExist(err) {
+		t.Fatalf("Directory of the removed file should be removed, but stat returned %v", err)
+	}
+}
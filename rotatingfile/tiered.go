@@ -0,0 +1,352 @@
+package rotatingfile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Murilovisque/logs/v3/internal/compressor"
+	"github.com/Murilovisque/logs/v3/internal/encryptor"
+	"github.com/Murilovisque/logs/v3/internal/fileutil"
+)
+
+var (
+	ErrInvalidDailyArchives           = errors.New("amount of daily archives is less than zero")
+	ErrTieredRetentionRequiresPerHour = errors.New("tiered retention requires the PerHour scheme")
+	errUnknownCompression             = errors.New("unknown compression extension")
+	errMissingEncryptionKeys          = errors.New("encrypted file without encryption keys")
+)
+
+// hourlyFile is an hourly file found by the tiered retention
+type hourlyFile struct {
+	filename    string
+	period      time.Time
+	sequence    int
+	finishedExt string
+	ft          *filenameTemplate
+}
+
+// daily returns the template of the daily archives, without %H and the separator before it, e.g.
+// app-%Y%m%d-%H{seq}.log becomes app-%Y%m%d{seq}.log and %Y/%m/%d/%H/app.log becomes %Y/%m/%d/app.log
+func (ft *filenameTemplate) daily() *filenameTemplate {
+	daily := filenameTemplate{hostname: ft.hostname}
+	for _, p := range ft.parts {
+		last := len(daily.parts) - 1
+		if p.token == tokenHour {
+			if last >= 0 && daily.parts[last].token == "" {
+				literal := daily.parts[last].literal
+				if strings.ContainsAny(literal[len(literal)-1:], "-_.T") {
+					daily.parts[last].literal = literal[:len(literal)-1]
+				}
+			}
+			continue
+		}
+		if p.token == "" && last >= 0 && daily.parts[last].token == "" {
+			// joins the literals around %H, e.g. the directories of %d/%H/app.log
+			literal := daily.parts[last].literal
+			if strings.HasSuffix(literal, "/") && strings.HasPrefix(p.literal, "/") {
+				literal = literal[:len(literal)-1]
+			}
+			daily.parts[last].literal = literal + p.literal
+			continue
+		}
+		daily.parts = append(daily.parts, p)
+	}
+	parts := daily.parts[:0]
+	for _, p := range daily.parts {
+		if p.token != "" || p.literal != "" {
+			parts = append(parts, p)
+		}
+	}
+	daily.parts = parts
+	daily.glob = daily.buildGlob()
+	return &daily
+}
+
+// dailyTemplate returns the template of the daily archives, in the archive directory when archiving
+func (w *Writer) dailyTemplate() *filenameTemplate {
+	if w.archiveDir != "" {
+		return w.nameTemplate().relocate(w.archiveDir).daily()
+	}
+	return w.nameTemplate().daily()
+}
+
+// retainTiered merges the hourly files of the days entirely older than the retention into daily archives and
+// removes the daily archives older than DailyArchives days
+func retainTiered(moment time.Time, holds holdGuard, w *Writer) {
+	lastFileTime := lastFileTimeToRetain(moment, w)
+	w.logger.Debugf("Last hourly file moment to retain %v", lastFileTime)
+	days := make(map[time.Time][]hourlyFile)
+	for _, f := range findHourlyFiles(w) {
+		day := truncateDay(f.period)
+		days[day] = append(days[day], f)
+	}
+	for day, files := range days {
+		if day.AddDate(0, 0, 1).After(lastFileTime) || !canBeConsolidated(files, holds, w) {
+			continue
+		}
+		consolidateDay(day, files, w)
+	}
+	removeOldDailyArchives(truncateDay(moment).AddDate(0, 0, -w.dailyArchives), holds, w)
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func findHourlyFiles(w *Writer) []hourlyFile {
+	var files []hourlyFile
+	current := w.Filename()
+	for _, ft := range w.templates() {
+		matcher, err := ft.matcher(w.finishedExtensionsRegex())
+		if err != nil {
+			w.reportError(OpCompress, ft.glob, err)
+			continue
+		}
+		fileEntries, err := filepath.Glob(ft.glob)
+		if err != nil {
+			w.reportError(OpCompress, ft.glob, err)
+			continue
+		}
+		for _, filename := range fileEntries {
+			if filename == current || filename == w.symlink || compressor.IsTempFile(filename) {
+				continue
+			}
+			period, finishedExt, ok := matcher.match(filename, w.now().Location())
+			if ok {
+				files = append(files, hourlyFile{filename, period, matcher.sequence(filename), finishedExt, ft})
+			}
+		}
+	}
+	return files
+}
+
+// canBeConsolidated is false while a file of the day is retained by a guard or still being finished
+func canBeConsolidated(files []hourlyFile, holds holdGuard, w *Writer) bool {
+	for _, f := range files {
+		if isRetained(f.filename, holds, w) {
+			return false
+		}
+		if temps, _ := filepath.Glob(escapeGlob(f.filename) + "*" + compressor.TempExtension); len(temps) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// consolidateDay merges the hourly files, in chronological order, into the daily archive, which is compressed,
+// encrypted and passed to the hooks, then removes them. A daily archive already written means a previous
+// consolidation was interrupted before removing the hourly files, so they are only removed
+func consolidateDay(day time.Time, files []hourlyFile, w *Writer) {
+	dailyFt := w.dailyTemplate()
+	dailyFilename := dailyFt.format(day, 0)
+	if !fileExists(dailyFilename) && !anyCompressedVariantExists(dailyFilename, w) {
+		sort.Slice(files, func(i, j int) bool {
+			if files[i].period.Equal(files[j].period) {
+				return files[i].sequence < files[j].sequence
+			}
+			return files[i].period.Before(files[j].period)
+		})
+		if err := mergeFiles(dailyFilename, files, w); err != nil {
+			w.reportError(OpCompress, dailyFilename, err)
+			return
+		}
+		w.logger.Infof("Hourly files of %s merged into %s", day.Format("2006-01-02"), dailyFilename)
+	}
+	if fileExists(dailyFilename) && !finishDailyArchive(dailyFilename, day, w) {
+		return
+	}
+	for _, f := range files {
+		if err := os.Remove(f.filename); err != nil {
+			w.reportError(OpRemove, f.filename, err)
+			continue
+		}
+		removeEmptyDirs(filepath.Dir(f.filename), f.ft)
+	}
+}
+
+// mergeFiles writes the decoded content of the files to a temporary file renamed to filename once complete
+func mergeFiles(filename string, files []hourlyFile, w *Writer) error {
+	tempFilename := filename + compressor.TempExtension
+	temp, err := w.permissions.OpenFile(tempFilename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY)
+	if err != nil {
+		return err
+	}
+	err = copyDecodedFiles(temp, files, w)
+	if err == nil {
+		err = temp.Sync()
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tempFilename, filename)
+	}
+	if err != nil {
+		os.Remove(tempFilename)
+		return err
+	}
+	fileutil.SyncDir(filepath.Dir(filename))
+	return nil
+}
+
+func copyDecodedFiles(dst io.Writer, files []hourlyFile, w *Writer) error {
+	for _, f := range files {
+		reader, err := openDecoded(f.filename, f.finishedExt, w)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(dst, reader)
+		reader.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// finishDailyArchive compresses, with gzip when there is no Compressor, and encrypts the daily archive. When the
+// encryption fails, it removes the plain archive and returns false, so the hourly files are consolidated again by
+// the next retention
+func finishDailyArchive(filename string, day time.Time, w *Writer) bool {
+	f := RotatedFile{Path: filename, Period: day, Archived: w.archiveDir != ""}
+	c := w.compressor
+	if c == nil {
+		c, _ = compressor.NewGzip(gzip.DefaultCompression)
+	}
+	if err := compress(c, filename, w); err != nil {
+		w.reportError(OpCompress, filename, err)
+	} else {
+		f.Path = filename + c.Extension()
+		f.Compressed = true
+	}
+	compressedPath := f.Path
+	var ok bool
+	if f.Path, ok = encryptFile(f.Path, w); !ok {
+		if err := os.Remove(compressedPath); err != nil {
+			w.reportError(OpRemove, compressedPath, err)
+		}
+		return false
+	}
+	f.Encrypted = f.Path != compressedPath
+	runPostRotateHooks(f, w)
+	return true
+}
+
+// openDecoded returns a reader of the decrypted and decompressed content of the file
+func openDecoded(filename, finishedExt string, w *Writer) (io.ReadCloser, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := decodedReader(f, finishedExt, w)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &decodedFile{ReadCloser: reader, file: f}, nil
+}
+
+// decodedReader decrypts the file while it is read, so the plain content is never written to disk. The zip archives
+// need random access, so the encrypted ones are decrypted in memory
+func decodedReader(f *os.File, finishedExt string, w *Writer) (io.ReadCloser, error) {
+	compressExt := strings.TrimSuffix(finishedExt, EncryptedExtension)
+	if !isEncrypted(finishedExt) {
+		if compressExt == "" {
+			return ioutil.NopCloser(f), nil
+		}
+		c, err := compressorFor(compressExt, w)
+		if err != nil {
+			return nil, err
+		}
+		info, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		return c.NewReader(f, info.Size())
+	}
+	if w.encryptionKeys == nil {
+		return nil, errMissingEncryptionKeys
+	}
+	decrypted, err := encryptor.NewReader(f, w.encryptionKeys)
+	if err != nil {
+		return nil, err
+	}
+	switch compressExt {
+	case "":
+		return ioutil.NopCloser(decrypted), nil
+	case compressor.GzipExtension:
+		return gzip.NewReader(decrypted)
+	}
+	c, err := compressorFor(compressExt, w)
+	if err != nil {
+		return nil, err
+	}
+	content, err := ioutil.ReadAll(decrypted)
+	if err != nil {
+		return nil, err
+	}
+	return c.NewReader(bytes.NewReader(content), int64(len(content)))
+}
+
+// decodedFile closes the reader and the file
+type decodedFile struct {
+	io.ReadCloser
+	file *os.File
+}
+
+func (d *decodedFile) Close() error {
+	err := d.ReadCloser.Close()
+	d.file.Close()
+	return err
+}
+
+func compressorFor(ext string, w *Writer) (Compressor, error) {
+	if w.compressor != nil && w.compressor.Extension() == ext {
+		return w.compressor, nil
+	}
+	switch ext {
+	case compressor.GzipExtension:
+		return compressor.NewGzip(gzip.DefaultCompression)
+	case compressor.ZipExtension:
+		return &compressor.Zip{}, nil
+	}
+	return nil, errUnknownCompression
+}
+
+// removeOldDailyArchives removes the daily archives of the days before lastDay
+func removeOldDailyArchives(lastDay time.Time, holds holdGuard, w *Writer) {
+	ft := w.dailyTemplate()
+	matcher, err := ft.matcher(w.finishedExtensionsRegex())
+	if err != nil {
+		w.reportError(OpRemove, ft.glob, err)
+		return
+	}
+	fileEntries, err := filepath.Glob(ft.glob)
+	if err != nil {
+		w.reportError(OpRemove, ft.glob, err)
+		return
+	}
+	for _, filename := range fileEntries {
+		if filename == w.symlink || compressor.IsTempFile(filename) || isRetained(filename, holds, w) {
+			continue
+		}
+		day, _, ok := matcher.match(filename, lastDay.Location())
+		if !ok || !day.Before(lastDay) {
+			continue
+		}
+		if err := os.Remove(filename); err != nil {
+			w.reportError(OpRemove, filename, err)
+		} else {
+			w.counters.countRemoval()
+			removeEmptyDirs(filepath.Dir(filename), ft)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package rotatingfile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Murilovisque/logs/v3/internal/compressor"
+	"github.com/Murilovisque/logs/v3/internal/encryptor"
+)
+
+func TestDailyTemplate(t *testing.T) {
+	moment := time.Date(2026, 10, 17, 5, 0, 0, 0, time.UTC)
+	tests := []struct {
+		template string
+		expected string
+	}{
+		{"/var/log/app-%Y%m%d-%H.log", "/var/log/app-20261017.log"},
+		{"/var/log/app.log.%Y-%m-%dT%H", "/var/log/app.log.2026-10-17"},
+		{"/var/log/%Y/%m/%d/%H/app.log", "/var/log/2026/10/17/app.log"},
+		{"/var/log/%Y/%m/%d/app_%H{seq}.log", "/var/log/2026/10/17/app.log"},
+	}
+	for _, tt := range tests {
+		ft, err := parseFilenameTemplate(tt.template, PerHour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if daily := ft.daily().format(moment, 0); daily != tt.expected {
+			t.Fatalf("Expected %s for %s, but received %s", tt.expected, tt.template, daily)
+		}
+	}
+	if daily := defaultFilenameTemplate("/var/log/app.log", PerHour).daily().format(moment, 1); daily != "/var/log/app-20261017.1.log" {
+		t.Fatalf("Unexpected daily name %s", daily)
+	}
+}
+
+func TestOpenWithInvalidTieredRetention(t *testing.T) {
+	for _, tt := range []struct {
+		opts     Options
+		expected error
+	}{
+		{Options{RotatingScheme: PerHour, DailyArchives: -1}, ErrInvalidDailyArchives},
+		{Options{RotatingScheme: PerDay, DailyArchives: 1}, ErrTieredRetentionRequiresPerHour},
+	} {
+		if _, err := Open("teste.log", tt.opts); err != tt.expected {
+			t.Fatalf("Expected %v, but received %v", tt.expected, err)
+		}
+	}
+}
+
+func TestOpenDecodedDoesNotWriteThePlainContent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	key := StaticKey(bytes.Repeat([]byte{7}, 32))
+	w := &Writer{encryptor: &encryptor.AESGCM{Keys: key}, encryptionKeys: key}
+	gz, err := compressor.NewGzip(gzip.BestSpeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []Compressor{nil, gz, &compressor.Zip{}} {
+		filename := filepath.Join(dir, "teste-20261017-05.log")
+		if err := ioutil.WriteFile(filename, []byte("secret\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		finishedExt := EncryptedExtension
+		if c != nil {
+			if err := compressor.CompressFile(c, filename, w.permissions); err != nil {
+				t.Fatal(err)
+			}
+			finishedExt = c.Extension() + EncryptedExtension
+		}
+		encrypted, ok := encryptFile(strings.TrimSuffix(filename+finishedExt, EncryptedExtension), w)
+		if !ok {
+			t.Fatal("Expected the file encrypted")
+		}
+		reader, err := openDecoded(encrypted, finishedExt, w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := ioutil.ReadAll(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != "secret\n" {
+			t.Fatalf("Expected the original content, but found %q", content)
+		}
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) != 1 || files[0].Name() != filepath.Base(encrypted) {
+			t.Fatalf("Expected only %s in the directory, but found %v", encrypted, files)
+		}
+		reader.Close()
+		os.Remove(encrypted)
+	}
+}
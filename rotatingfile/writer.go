@@ -0,0 +1,1003 @@
+// Package rotatingfile provides an io.WriteCloser writing to files rotated by time
+package rotatingfile
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Murilovisque/logs/v3/internal/compressor"
+	"github.com/Murilovisque/logs/v3/internal/encryptor"
+	"github.com/Murilovisque/logs/v3/internal/fileutil"
+)
+
+// Compressor produces the archive written in place of a rotated file
+type Compressor = compressor.Compressor
+
+// Owner of the files and directories created, applied only when the process runs as root
+type Owner = fileutil.Owner
+
+type TimeRotatingScheme string
+
+const (
+	PerDay  TimeRotatingScheme = "perDay"
+	PerHour TimeRotatingScheme = "perHour"
+)
+
+var (
+	ErrInvalidAmountOfFilesToRetain  = errors.New("amount of files to retain is less than zero")
+	ErrInvalidCompressionConcurrency = errors.New("compression concurrency is less than zero")
+	ErrClosed                        = errors.New("rotating file writer is closed")
+	ErrCircularNotSupported          = errors.New("circular file is opened with circularfile.Open")
+)
+
+// Logger receives the messages about the writer operations
+type Logger interface {
+	Debugf(message string, v ...interface{})
+	Infof(message string, v ...interface{})
+	Errorf(message string, v ...interface{})
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debugf(message string, v ...interface{}) {}
+func (nopLogger) Infof(message string, v ...interface{})  {}
+func (nopLogger) Errorf(message string, v ...interface{}) {}
+
+// Options configures a Writer
+type Options struct {
+	RotatingScheme        TimeRotatingScheme
+	AmountOfFilesToRetain int
+	// DailyArchives enables the tiered retention of PerHour: the hourly files of a day are kept until the whole day
+	// is older than AmountOfFilesToRetain hours, then merged in order into a compressed daily archive, e.g.
+	// app-20261017.log.gz, of which DailyArchives days are kept. Zero disables it
+	DailyArchives int
+	// Compressor compresses the old files after rotating. Nil disables the compression
+	Compressor Compressor
+	// CompressionConcurrency is the amount of workers compressing and removing old files. Zero means one worker
+	CompressionConcurrency int
+	// CompressionNiceness is the CPU niceness of the compression workers, only applied on linux
+	CompressionNiceness int
+	// Encryption encrypts the files with AES-256-GCM after compressing them, using the key provided. Nil disables
+	// the encryption
+	Encryption KeySource
+	// Symlink is kept pointing to the current log file, e.g. app.log. Empty disables it
+	Symlink string
+	// FilenameTemplate names the files, e.g. /var/log/%Y/%m/%d/app{seq}.log or /var/log/app.log.%Y-%m-%d. The tokens
+	// are %Y, %m, %d, %H, {seq} and {hostname}, see filenameTemplate. Missing directories are created. Empty names
+	// the files as <filename without ext>-<period><ext>, e.g. app-20261017.log
+	FilenameTemplate string
+	// ArchiveDir receives the finished files, after compressed, keeping the directories created by the
+	// FilenameTemplate. The retention is applied to it. Empty keeps the files next to the current one
+	ArchiveDir string
+	// FileMode of the files created, including the compressed ones. Zero means 0644
+	FileMode os.FileMode
+	// DirMode of the directories created. Zero means 0755
+	DirMode os.FileMode
+	// Owner is applied to the files and directories created when the process runs as root. Nil keeps the owner
+	Owner *Owner
+	// PostRotateHooks are called with each file finished after rotating
+	PostRotateHooks []PostRotateHook
+	// RetentionGuards keep the files they retain, even if older than the retention. The legal holds, see Hold, are
+	// always respected
+	RetentionGuards []RetentionGuard
+	// BufferSize is the size of the buffer the writes go through. Zero writes straight to the file
+	BufferSize int
+	// FlushInterval is how often the buffer is written to the file. Zero means one second
+	FlushInterval time.Duration
+	// SyncPolicy defines when the file is fsynced. Zero is SyncNever
+	SyncPolicy SyncPolicy
+	// SyncInterval is how often the file is fsynced with SyncPeriodically. Zero means one second
+	SyncInterval time.Duration
+	// MultiProcess coordinates the processes writing to the same files with flock: the scheduled rotation makes them
+	// all join the same file and a single one compresses and removes the files no process writes anymore. The
+	// entries are interleaved per write, so BufferSize should be zero. Supported on linux, darwin and freebsd
+	MultiProcess bool
+	// DiskGuard checks the free space of the file system periodically, removing old files, dropping entries and
+	// stopping the writes when it is low. Nil disables it
+	DiskGuard *DiskGuardOptions
+	// StreamCompression writes the current file through a gzip stream, e.g. app-20261017.log.gz, flushed every
+	// FlushInterval so it is readable up to the last flush after a crash. The rotation finalizes the stream, so the
+	// Compressor is not used
+	StreamCompression bool
+	// StreamCompressionLevel is the gzip level of the stream. Zero means gzip.DefaultCompression
+	StreamCompressionLevel int
+	// CircularSize replaces the rotating files by a preallocated circular file of this size, see circularfile, for
+	// storages where rotating and removing files wears the flash. Only the loggers support it, applying FileMode,
+	// DirMode and Owner to the file and ignoring the other options. Zero rotates the files
+	CircularSize int64
+	// Header writes a block with the process metadata and the writer configuration at the top of each new file
+	Header bool
+	// HeaderFormatter encodes the header. Nil uses TextHeaderFormatter
+	HeaderFormatter HeaderFormatter
+	// HeaderFields are added to the header, e.g. the level of a logger
+	HeaderFields []HeaderField
+	// Clock provides the time, e.g. a fake one in tests. Nil uses the system time
+	Clock Clock
+	// Logger receives the messages about rotation, compression and removal of files. Nil discards them
+	Logger Logger
+	// ErrorHandler receives the failures to write, open, rotate, compress, archive and remove the files, instead of
+	// the Logger, which may write to the failing file. Nil writes them to os.Stderr, at most one per second
+	ErrorHandler ErrorHandler
+}
+
+// NewGzipCompressor creates a compressor producing .gz files. The level must be between gzip.HuffmanOnly and gzip.BestCompression
+func NewGzipCompressor(level int) (Compressor, error) {
+	c, err := compressor.NewGzip(level)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewZipCompressor creates a compressor producing .zip files
+func NewZipCompressor() Compressor {
+	return &compressor.Zip{}
+}
+
+func (trs TimeRotatingScheme) rotatingInterval() time.Duration {
+	switch trs {
+	case PerDay:
+		return time.Hour * 24
+	case PerHour:
+		return time.Hour
+	default:
+		panic("Not implemented")
+	}
+}
+
+func (trs TimeRotatingScheme) nextTruncatedTimeAfter(t time.Time) time.Time {
+	switch trs {
+	case PerDay:
+		t = t.AddDate(0, 0, 1)
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	case PerHour:
+		t = t.Add(time.Hour)
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	default:
+		panic("Not implemented")
+	}
+}
+
+func (trs TimeRotatingScheme) truncate(t time.Time) time.Time {
+	switch trs {
+	case PerDay:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	case PerHour:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	default:
+		panic("Not implemented")
+	}
+}
+
+// requiredTokens are the filename template tokens that make the names of two periods different
+func (trs TimeRotatingScheme) requiredTokens() []string {
+	switch trs {
+	case PerDay:
+		return []string{tokenYear, tokenMonth, tokenDay}
+	case PerHour:
+		return []string{tokenYear, tokenMonth, tokenDay, tokenHour}
+	default:
+		panic("Not implemented")
+	}
+}
+
+// Writer is an io.WriteCloser writing to a file that is rotated by time, with retention and compression of the old files
+type Writer struct {
+	rotatingScheme         TimeRotatingScheme
+	filename               string
+	currentLogFilename     string
+	file                   *os.File
+	buf                    *bufio.Writer
+	stream                 *gzip.Writer
+	streamCompression      bool
+	streamCompressionLevel int
+	bufferSize             int
+	flushInterval          time.Duration
+	syncPolicy             SyncPolicy
+	syncInterval           time.Duration
+	stopBackground         chan struct{}
+	background             sync.WaitGroup
+	diskGuard              *DiskGuardOptions
+	diskState              int32
+	multiProcess           bool
+	freeSpace              func(dir string) (uint64, error)
+	alertOutput            io.Writer
+	mux                    sync.Mutex
+	amountOfFilesToRetain  int
+	compressor             compressor.Compressor
+	encryptor              compressor.Compressor
+	encryptionKeys         KeySource
+	dailyArchives          int
+	queue                  *workerQueue
+	symlink                string
+	template               *filenameTemplate
+	archiveDir             string
+	permissions            fileutil.Permissions
+	postRotateHooks        []PostRotateHook
+	retentionGuards        []RetentionGuard
+	holdMux                sync.Mutex
+	heldFiles              []string
+	header                 bool
+	headerFormatter        HeaderFormatter
+	headerFields           []HeaderField
+	clock                  Clock
+	logger                 Logger
+	errorHandler           ErrorHandler
+	rotateMux              sync.Mutex
+	retentionMux           sync.Mutex
+	finishMux              sync.Mutex
+	finishDone             *sync.Cond
+	finishing              map[*finishJob]bool
+	counters               *counters
+	rotationStopped        bool
+	closeSignalListener    chan int
+	closedListener         chan int
+	started                bool
+	closed                 bool
+	closeOnce              sync.Once
+	closeDone              chan struct{}
+	closeErr               error
+}
+
+// New opens the file of the current period and starts rotating it
+func New(filename string, opts Options) (*Writer, error) {
+	w, err := Open(filename, opts)
+	if err != nil {
+		return nil, err
+	}
+	w.Start()
+	return w, nil
+}
+
+// Open opens the file of the current period without rotating it until Start is called. It allows to finish
+// setting up the Options.Logger before the writer starts to log
+func Open(filename string, opts Options) (*Writer, error) {
+	if opts.CircularSize != 0 {
+		return nil, ErrCircularNotSupported
+	}
+	if opts.AmountOfFilesToRetain < 0 {
+		return nil, ErrInvalidAmountOfFilesToRetain
+	}
+	if opts.DailyArchives < 0 {
+		return nil, ErrInvalidDailyArchives
+	}
+	if opts.DailyArchives > 0 && opts.RotatingScheme != PerHour {
+		return nil, ErrTieredRetentionRequiresPerHour
+	}
+	if opts.CompressionConcurrency < 0 {
+		return nil, ErrInvalidCompressionConcurrency
+	}
+	if opts.BufferSize < 0 {
+		return nil, ErrInvalidBufferSize
+	}
+	if opts.FlushInterval < 0 {
+		return nil, ErrInvalidFlushInterval
+	}
+	if opts.SyncInterval < 0 {
+		return nil, ErrInvalidSyncInterval
+	}
+	if opts.MultiProcess && !fileLockSupported {
+		return nil, ErrMultiProcessNotSupported
+	}
+	if opts.StreamCompression && opts.MultiProcess {
+		return nil, ErrStreamCompressionMultiProcess
+	}
+	if opts.StreamCompressionLevel < gzip.HuffmanOnly || opts.StreamCompressionLevel > gzip.BestCompression {
+		return nil, ErrInvalidStreamCompressionLevel
+	}
+	if opts.Encryption != nil {
+		if _, err := opts.Encryption.Key(); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Symlink != "" {
+		if err := checkSymlink(opts.Symlink); err != nil {
+			return nil, err
+		}
+	}
+	template := defaultFilenameTemplate(filename, opts.RotatingScheme)
+	if opts.FilenameTemplate != "" {
+		var err error
+		template, err = parseFilenameTemplate(opts.FilenameTemplate, opts.RotatingScheme)
+		if err != nil {
+			return nil, err
+		}
+	}
+	w := Writer{
+		rotatingScheme:         opts.RotatingScheme,
+		filename:               filename,
+		counters:               &counters{},
+		closeSignalListener:    make(chan int),
+		closedListener:         make(chan int, 1),
+		amountOfFilesToRetain:  opts.AmountOfFilesToRetain,
+		compressor:             opts.Compressor,
+		queue:                  newWorkerQueue(opts.CompressionConcurrency, opts.CompressionNiceness),
+		symlink:                opts.Symlink,
+		template:               template,
+		archiveDir:             opts.ArchiveDir,
+		permissions:            fileutil.Permissions{FileMode: opts.FileMode, DirMode: opts.DirMode, Owner: opts.Owner},
+		postRotateHooks:        opts.PostRotateHooks,
+		retentionGuards:        opts.RetentionGuards,
+		bufferSize:             opts.BufferSize,
+		dailyArchives:          opts.DailyArchives,
+		streamCompression:      opts.StreamCompression,
+		streamCompressionLevel: opts.StreamCompressionLevel,
+		flushInterval:          opts.FlushInterval,
+		syncPolicy:             opts.SyncPolicy,
+		syncInterval:           opts.SyncInterval,
+		stopBackground:         make(chan struct{}),
+		freeSpace:              diskFreeSpace,
+		multiProcess:           opts.MultiProcess,
+		alertOutput:            os.Stderr,
+		header:                 opts.Header,
+		headerFormatter:        opts.HeaderFormatter,
+		headerFields:           opts.HeaderFields,
+		clock:                  opts.Clock,
+		logger:                 opts.Logger,
+		errorHandler:           opts.ErrorHandler,
+	}
+	if w.clock == nil {
+		w.clock = realClock{}
+	}
+	if w.flushInterval == 0 {
+		w.flushInterval = defaultFlushInterval
+	}
+	if w.syncInterval == 0 {
+		w.syncInterval = defaultSyncInterval
+	}
+	if w.streamCompressionLevel == 0 {
+		w.streamCompressionLevel = gzip.DefaultCompression
+	}
+	if opts.DiskGuard != nil {
+		if err := opts.DiskGuard.validate(); err != nil {
+			return nil, err
+		}
+		diskGuard := *opts.DiskGuard
+		w.diskGuard = &diskGuard
+	}
+	if w.logger == nil {
+		w.logger = nopLogger{}
+	}
+	if w.errorHandler == nil {
+		w.errorHandler = defaultErrorHandler()
+	}
+	if opts.Encryption != nil {
+		w.encryptor = &encryptor.AESGCM{Keys: opts.Encryption}
+		w.encryptionKeys = opts.Encryption
+	}
+	removePartialArchives(&w)
+	newFilename := buildFilenameToResume(w.now(), &w)
+	if w.streamCompression {
+		// a stream left by a previous process can't be continued
+		newFilename = w.activeFilename(buildFilenameToRotate(w.now(), &w))
+	}
+	f, stream, err := openActiveFile(newFilename, "", &w)
+	if err != nil {
+		if f != nil {
+			f.Close()
+		}
+		return nil, err
+	}
+	if opts.Symlink != "" {
+		if err := updateSymlink(opts.Symlink, newFilename); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	w.currentLogFilename = newFilename
+	w.file = f
+	w.stream = stream
+	w.buf = newBuffer(&w)
+	return &w, nil
+}
+
+// Start compresses the files of previous periods left uncompressed and starts the rotation. The tickers are created
+// before Start returns, so a fake Clock can be advanced right after it
+func (w *Writer) Start() {
+	w.started = true
+	w.queue.start()
+	if w.multiProcess {
+		moment := w.nowTruncated()
+		w.queue.enqueue(func() {
+			finishFilesAsLeader(moment, w)
+		})
+	} else {
+		finishPreviousFiles(w)
+	}
+	w.logger.Infof("Starting the log rotation: %v scheme", w.rotatingScheme)
+	next := durationUntilNextRotating(w.now(), w.rotatingScheme)
+	w.logger.Debugf("Next log rotation will be at %v", next)
+	go rotatingFile(w, w.clock.NewTicker(next))
+	if w.mustFlushPeriodically() {
+		flushTick, syncTick := w.flushTickers()
+		w.background.Add(1)
+		go flushing(w, flushTick, syncTick)
+	}
+	if w.diskGuard != nil {
+		checkDiskSpace(w)
+		w.background.Add(1)
+		go guardingDisk(w, w.clock.NewTicker(w.diskCheckInterval()))
+	}
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	n, err := w.write(p)
+	w.counters.countWrite(n, err)
+	// the writes dropped by the disk guard are reported by its alert, not as failures
+	if err != nil && err != ErrClosed && err != ErrDiskFull {
+		w.reportError(OpWrite, w.Filename(), err)
+	}
+	return n, err
+}
+
+func (w *Writer) write(p []byte) (int, error) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	if w.closed {
+		return 0, ErrClosed
+	}
+	if w.DiskState() == DiskFull {
+		return 0, ErrDiskFull
+	}
+	var n int
+	var err error
+	if w.buf == nil {
+		n, err = w.sink().Write(p)
+	} else {
+		n, err = w.buf.Write(p)
+	}
+	if err != nil || w.syncPolicy != SyncEveryWrite {
+		return n, err
+	}
+	return n, w.syncFile()
+}
+
+// Rotate rotates to a new file immediately, compressing and removing the old files as the scheduled rotation does.
+// Files rotated within the same period receive a sequence suffix, e.g. app-20261017.1.log
+func (w *Writer) Rotate() error {
+	return rotate(w.nowTruncated(), true, w)
+}
+
+// WaitIdle blocks until the compressions, archivings and removals enqueued so far are done
+func (w *Writer) WaitIdle() {
+	w.queue.waitIdle()
+}
+
+// Filename returns the name of the file being written
+func (w *Writer) Filename() string {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	return w.currentLogFilename
+}
+
+// Close stops the rotation, waits the pending compressions, removes the old files, then flushes, syncs and closes the
+// current file. The first call of Close or Shutdown returns the error of the closing, the other ones wait the same
+// closing and return ErrClosed. The writes after it return ErrClosed
+func (w *Writer) Close() error {
+	first := w.startClosing()
+	<-w.closeDone
+	if !first {
+		return ErrClosed
+	}
+	return w.closeErr
+}
+
+// Shutdown closes the writer as Close, waiting until the closing is done or ctx is done, when it returns ctx.Err()
+// and the closing goes on in background. It can be called many times, even concurrently, returning ErrClosed as
+// Close when it is not the first call
+func (w *Writer) Shutdown(ctx context.Context) error {
+	first := w.startClosing()
+	select {
+	case <-w.closeDone:
+		if !first {
+			return ErrClosed
+		}
+		return w.closeErr
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// startClosing closes the writer in background on the first call, returning whether it was the first one
+func (w *Writer) startClosing() bool {
+	first := false
+	w.closeOnce.Do(func() {
+		first = true
+		w.closeDone = make(chan struct{})
+		go func() {
+			w.closeErr = w.close()
+			close(w.closeDone)
+		}()
+	})
+	return first
+}
+
+func (w *Writer) close() error {
+	if w.started {
+		w.closeSignalListener <- 1
+		<-w.closedListener
+		close(w.stopBackground)
+		w.background.Wait()
+	}
+	w.rotateMux.Lock()
+	w.rotationStopped = true
+	w.rotateMux.Unlock()
+	if w.started {
+		w.queue.stop()
+	}
+	moment := w.nowTruncated()
+	if w.multiProcess {
+		withLeaderLock(w, func() {
+			removeOldFiles(moment, w)
+		})
+	} else {
+		removeOldFiles(moment, w)
+	}
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	w.closed = true
+	return w.closeFile()
+}
+
+func durationUntilNextRotating(moment time.Time, rotatingScheme TimeRotatingScheme) time.Duration {
+	nextRotatingTime := rotatingScheme.nextTruncatedTimeAfter(moment)
+	nextDuration := nextRotatingTime.Sub(moment)
+	if nextDuration < 1 {
+		return 1
+	}
+	return nextDuration
+}
+
+func buildFilenameWithTimeExtension(moment time.Time, filename string, rotatingScheme TimeRotatingScheme) string {
+	return buildFilenameWithSequence(moment, filename, rotatingScheme, 0)
+}
+
+// buildFilenameWithSequence adds the sequence suffix when it is greater than zero, e.g. app-20261017.1.log
+func buildFilenameWithSequence(moment time.Time, filename string, rotatingScheme TimeRotatingScheme, sequence int) string {
+	return defaultFilenameTemplate(filename, rotatingScheme).format(moment, sequence)
+}
+
+// now returns the time from the configured clock, or the system time when there is none
+func (w *Writer) now() time.Time {
+	if w.clock == nil {
+		return time.Now()
+	}
+	return w.clock.Now()
+}
+
+func (w *Writer) nowTruncated() time.Time {
+	return w.rotatingScheme.truncate(w.now())
+}
+
+// nameTemplate returns the default template when the writer was not created by Open
+func (w *Writer) nameTemplate() *filenameTemplate {
+	if w.template == nil {
+		return defaultFilenameTemplate(w.filename, w.rotatingScheme)
+	}
+	return w.template
+}
+
+// buildFilenameToRotate returns the first filename of the period that was never used
+func buildFilenameToRotate(moment time.Time, w *Writer) string {
+	for sequence := 0; ; sequence++ {
+		filename := w.nameTemplate().format(moment, sequence)
+		if !anyFileVariantExists(filename, w) {
+			return filename
+		}
+	}
+}
+
+// buildFilenameToResume returns the last filename of the period that is still uncompressed, so a restarted process
+// keeps appending to it, or the first filename never used
+func buildFilenameToResume(moment time.Time, w *Writer) string {
+	lastUncompressed := ""
+	for sequence := 0; ; sequence++ {
+		filename := w.nameTemplate().format(moment, sequence)
+		if !anyFileVariantExists(filename, w) {
+			if lastUncompressed != "" {
+				return lastUncompressed
+			}
+			return filename
+		}
+		lastUncompressed = ""
+		if fileExists(filename) && !isFinished(filename, w) {
+			lastUncompressed = filename
+		}
+	}
+}
+
+func anyFileVariantExists(filename string, w *Writer) bool {
+	return fileExists(filename) || isFinished(filename, w)
+}
+
+// isFinished reports whether the file was already compressed or archived
+func isFinished(filename string, w *Writer) bool {
+	if anyCompressedVariantExists(filename, w) {
+		return true
+	}
+	if w.archiveDir == "" {
+		return false
+	}
+	archivedFilename := archivePath(filename, w)
+	return fileExists(archivedFilename) || anyCompressedVariantExists(archivedFilename, w)
+}
+
+func anyCompressedVariantExists(filename string, w *Writer) bool {
+	for _, ext := range append(compressor.Extensions(w.compressor), "") {
+		for _, variant := range []string{filename + ext, filename + ext + EncryptedExtension} {
+			if variant == filename {
+				continue
+			}
+			if fileExists(variant) || fileExists(variant+compressor.TempExtension) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// templates returns the template of the current files and, when archiving, the template of the archived ones
+func (w *Writer) templates() []*filenameTemplate {
+	if w.archiveDir == "" {
+		return []*filenameTemplate{w.nameTemplate()}
+	}
+	return []*filenameTemplate{w.nameTemplate(), w.nameTemplate().relocate(w.archiveDir)}
+}
+
+// openFile creates the missing directories of the filename
+func openFile(filename string, w *Writer) (*os.File, error) {
+	return w.permissions.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY)
+}
+
+func fileExists(filename string) bool {
+	_, err := os.Lstat(filename)
+	return err == nil
+}
+
+func lastFileTimeToRetain(moment time.Time, w *Writer) time.Time {
+	return moment.Add(w.rotatingScheme.rotatingInterval() * time.Duration(w.amountOfFilesToRetain) * -1)
+}
+
+func rotatedFilenameMatcher(w *Writer) (*filenameMatcher, error) {
+	return w.nameTemplate().matcher(w.finishedExtensionsRegex())
+}
+
+func mustFileBeRemoved(lastFileTime time.Time, filenameToCheck string, w *Writer) bool {
+	for _, ft := range w.templates() {
+		if mustFileBeRemovedByTemplate(lastFileTime, filenameToCheck, ft, w) {
+			return true
+		}
+	}
+	return false
+}
+
+func mustFileBeRemovedByTemplate(lastFileTime time.Time, filenameToCheck string, ft *filenameTemplate, w *Writer) bool {
+	matcher, err := ft.matcher(w.finishedExtensionsRegex())
+	if err != nil {
+		w.reportError(OpRemove, ft.glob, err)
+		return false
+	}
+	fileTime, _, ok := matcher.match(filenameToCheck, lastFileTime.Location())
+	if !ok {
+		return false
+	}
+	return fileTime.Before(lastFileTime)
+}
+
+func getFilenameWithoutExt(filename string) string {
+	filenameExt := getFilenameExt(filename, true)
+	return filename[:len(filename)-len(filenameExt)]
+}
+
+func getFilenameGlobWithoutExt(filename string) string {
+	filenameExt := getFilenameExt(filename, true)
+	return filename[:len(filename)-len(filenameExt)] + "*"
+}
+
+func getFilenameExt(filename string, includeCompressExtension bool) string {
+	filenameSize := len(filename)
+	for _, compressExt := range compressor.Extensions(nil) {
+		if strings.HasSuffix(filename, compressExt) {
+			ext := path.Ext(filename[:filenameSize-len(compressExt)])
+			if includeCompressExtension {
+				return ext + compressExt
+			}
+			return ext
+		}
+	}
+	return path.Ext(filename)
+}
+
+// removeOldFiles waits the files being finished, so it never removes a file being compressed
+func removeOldFiles(moment time.Time, w *Writer) {
+	w.retentionMux.Lock()
+	defer w.retentionMux.Unlock()
+	w.waitFinishes()
+	holds := w.loadHolds()
+	defer w.updateHeldFiles(moment, holds)
+	if w.dailyArchives > 0 {
+		retainTiered(moment, holds, w)
+		return
+	}
+	lastFileTime := lastFileTimeToRetain(moment, w)
+	w.logger.Debugf("Last file moment to retain %v", lastFileTime)
+	for _, ft := range w.templates() {
+		fileEntries, err := filepath.Glob(ft.glob)
+		if err != nil {
+			w.reportError(OpRemove, ft.glob, err)
+			continue
+		}
+		for _, filename := range fileEntries {
+			if filename == w.symlink || isRetained(filename, holds, w) {
+				continue
+			}
+			if mustFileBeRemovedByTemplate(lastFileTime, filename, ft, w) {
+				err := os.Remove(filename)
+				if err != nil {
+					w.reportError(OpRemove, filename, err)
+				} else {
+					w.counters.countRemoval()
+					removeEmptyDirs(filepath.Dir(filename), ft)
+				}
+			}
+		}
+	}
+}
+
+// removeEmptyDirs removes the empty directories created by the template tokens, e.g. /var/log/2026/10/17
+func removeEmptyDirs(dir string, ft *filenameTemplate) {
+	staticDir := ft.staticDir()
+	for len(dir) > len(staticDir) && strings.HasPrefix(dir, staticDir) {
+		if os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+func isPartialArchive(filenameToCheck string, ft *filenameTemplate, w *Writer) bool {
+	if !compressor.IsTempFile(filenameToCheck) {
+		return false
+	}
+	matcher, err := ft.matcher(w.finishedExtensionsRegex())
+	if err != nil {
+		return false
+	}
+	_, _, ok := matcher.match(filenameToCheck[:len(filenameToCheck)-len(compressor.TempExtension)], w.now().Location())
+	return ok
+}
+
+func removePartialArchives(w *Writer) {
+	for _, ft := range w.retentionTemplates() {
+		fileEntries, err := filepath.Glob(ft.glob)
+		if err != nil {
+			continue
+		}
+		for _, filename := range fileEntries {
+			if isPartialArchive(filename, ft, w) {
+				os.Remove(filename)
+			}
+		}
+	}
+}
+
+// compressFile returns the name of the compressed file, or filename when it was not compressed
+func compressFile(filename string, w *Writer) string {
+	if w.compressor == nil {
+		return filename
+	}
+	err := compress(w.compressor, filename, w)
+	if err != nil {
+		w.reportError(OpCompress, filename, err)
+		return filename
+	}
+	return filename + w.compressor.Extension()
+}
+
+// finishFile compresses, encrypts and archives the file after it was rotated, then calls the hooks. A file that
+// could not be encrypted is left in place, so finishPreviousFiles retries it at the next start
+func finishFile(filename string, w *Writer) {
+	f := RotatedFile{Path: filename}
+	finishedExt := ""
+	if matcher, err := rotatedFilenameMatcher(w); err == nil {
+		f.Period, finishedExt, _ = matcher.match(filename, w.now().Location())
+	}
+	if finishedExt == "" {
+		f.Path = compressFile(filename, w)
+	}
+	f.Compressed = isCompressed(finishedExt) || f.Path != filename
+	if !isEncrypted(finishedExt) {
+		compressedPath := f.Path
+		var ok bool
+		if f.Path, ok = encryptFile(f.Path, w); !ok {
+			return
+		}
+		f.Encrypted = f.Path != compressedPath
+	} else {
+		f.Encrypted = true
+	}
+	archiveFile(f.Path, w, &f)
+	runPostRotateHooks(f, w)
+}
+
+// finishPreviousFiles enqueues the files of previous periods left uncompressed or not archived, e.g. when the
+// process died before rotating
+func finishPreviousFiles(w *Writer) {
+	if w.compressor == nil && w.encryptor == nil && w.archiveDir == "" {
+		return
+	}
+	matcher, err := rotatedFilenameMatcher(w)
+	if err != nil {
+		w.reportError(OpFinish, w.filename, err)
+		return
+	}
+	filenameGlob := w.nameTemplate().glob
+	fileEntries, err := filepath.Glob(filenameGlob)
+	if err != nil {
+		w.reportError(OpFinish, filenameGlob, err)
+		return
+	}
+	currentPeriod := w.nowTruncated()
+	for _, filename := range fileEntries {
+		if filename == w.currentLogFilename {
+			continue
+		}
+		fileTime, finishedExt, ok := matcher.match(filename, currentPeriod.Location())
+		if !ok || !fileTime.Before(currentPeriod) {
+			continue
+		}
+		previousFilename := filename
+		if finishedExt == "" || w.archiveDir != "" || (w.encryptor != nil && !isEncrypted(finishedExt)) {
+			w.enqueueFinish(previousFilename)
+		}
+	}
+}
+
+// rotate opens a new file. In multi-process mode, the scheduled rotation joins the file of the period opened by
+// other processes, while the manual one always opens a new file
+func rotate(moment time.Time, manual bool, w *Writer) error {
+	w.rotateMux.Lock()
+	defer w.rotateMux.Unlock()
+	if w.rotationStopped {
+		return ErrClosed
+	}
+	w.logger.Debugf("Starting log rotating operation %v", moment)
+	newFilename := buildFilenameToRotate(moment, w)
+	if w.multiProcess && !manual {
+		newFilename = buildFilenameToResume(moment, w)
+	}
+	newFilename = w.activeFilename(newFilename)
+	oldLogFilename := w.currentLogFilename
+	f, stream, err := openActiveFile(newFilename, oldLogFilename, w)
+	if f == nil {
+		w.reportError(OpOpen, newFilename, err)
+		w.finishFiles(moment, "")
+		return err
+	}
+	if err != nil {
+		w.reportError(OpOpen, newFilename, err)
+	}
+	w.mux.Lock()
+	if err := w.closeFile(); err != nil {
+		w.reportError(OpRotate, oldLogFilename, err)
+	}
+	w.currentLogFilename = newFilename
+	w.file = f
+	w.stream = stream
+	if w.buf != nil {
+		w.buf.Reset(w.sink())
+	}
+	w.mux.Unlock()
+	w.counters.countRotation()
+	if w.symlink != "" {
+		if err := updateSymlink(w.symlink, newFilename); err != nil {
+			w.reportError(OpSymlink, w.symlink, err)
+		}
+	}
+	w.finishFiles(moment, oldLogFilename)
+	w.logger.Debugf("Log rotated to new file: %s", newFilename)
+	return nil
+}
+
+// finishFiles enqueues the finishing of the rotated file, if any, and the removal of the old files. In
+// multi-process mode, it is done by a single process for the files no process writes anymore
+func (w *Writer) finishFiles(moment time.Time, rotatedFilename string) {
+	if w.multiProcess {
+		w.queue.enqueue(func() {
+			finishFilesAsLeader(moment, w)
+		})
+		return
+	}
+	if rotatedFilename != "" {
+		w.enqueueFinish(rotatedFilename)
+	}
+	w.queue.enqueue(func() {
+		removeOldFiles(moment, w)
+	})
+}
+
+// finishJob is a file queued to be finished, claimed by the worker running it or by the retention
+type finishJob struct {
+	filename string
+	claimed  bool
+}
+
+// enqueueFinish enqueues the finishing of the file, tracked until it is done so the retention can wait it
+func (w *Writer) enqueueFinish(filename string) {
+	job := &finishJob{filename: filename}
+	w.finishMux.Lock()
+	if w.finishing == nil {
+		w.finishing = make(map[*finishJob]bool)
+		w.finishDone = sync.NewCond(&w.finishMux)
+	}
+	w.finishing[job] = true
+	w.finishMux.Unlock()
+	w.queue.enqueue(func() {
+		w.finishMux.Lock()
+		claimed := job.claimed
+		job.claimed = true
+		w.finishMux.Unlock()
+		if !claimed {
+			w.runFinish(job)
+		}
+	})
+}
+
+func (w *Writer) runFinish(job *finishJob) {
+	finishFile(job.filename, w)
+	w.finishMux.Lock()
+	delete(w.finishing, job)
+	w.finishDone.Broadcast()
+	w.finishMux.Unlock()
+}
+
+// waitFinishes finishes the files still queued and waits the ones being finished by other workers. The queued ones
+// are finished by the caller, as they may be queued after it
+func (w *Writer) waitFinishes() {
+	w.finishMux.Lock()
+	defer w.finishMux.Unlock()
+	for len(w.finishing) > 0 {
+		var queued *finishJob
+		for job := range w.finishing {
+			if !job.claimed {
+				queued = job
+				break
+			}
+		}
+		if queued == nil {
+			w.finishDone.Wait()
+			continue
+		}
+		queued.claimed = true
+		w.finishMux.Unlock()
+		w.runFinish(queued)
+		w.finishMux.Lock()
+	}
+}
+
+func rotatingFile(w *Writer, tick Ticker) {
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C():
+			moment := w.nowTruncated()
+			// the failure to open the new file is reported by rotate, which keeps writing to the current one
+			rotate(moment, false, w)
+			next := durationUntilNextRotating(w.now(), w.rotatingScheme)
+			tick.Reset(next)
+			w.logger.Debugf("Log rotating operation finished, next will be at %v", next)
+		case <-w.closeSignalListener:
+			w.closedListener <- 1
+			return
+		}
+	}
+}
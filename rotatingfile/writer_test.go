@@ -0,0 +1,554 @@
+package rotatingfile
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Murilovisque/logs/v3/internal/compressor"
+)
+
+func TestDurationUntilNextRotating(t *testing.T) {
+	now, _ := time.Parse("2006 Jan 02 15:04:05", "2012 Dec 07 12:15:30")
+	expectedPerDay := 11*time.Hour + 44*time.Minute + 30*time.Second
+	vl := durationUntilNextRotating(now, PerDay)
+	if vl != expectedPerDay {
+		t.Fatal(vl)
+	}
+	expectedPerHour := 44*time.Minute + 30*time.Second
+	vl = durationUntilNextRotating(now, PerHour)
+	if vl != expectedPerHour {
+		t.Fatal(vl)
+	}
+}
+
+func TestBuildFilenameWithTimeExtension(t *testing.T) {
+	now, _ := time.Parse("2006 Jan 02 15:04:05", "2012 Dec 07 06:15:30")
+	tests := []struct {
+		vl             string
+		exp            string
+		rotatingScheme TimeRotatingScheme
+	}{
+		{"/varl/log/teste.log", "/varl/log/teste-20121207.log", PerDay},
+		{"/varl/log/teste", "/varl/log/teste-20121207", PerDay},
+		{"/varl/log/", "/varl/log/-20121207", PerDay},
+		{"/varl/log/teste.log", "/varl/log/teste-20121207-06.log", PerHour},
+		{"/varl/log/teste", "/varl/log/teste-20121207-06", PerHour},
+		{"/varl/log/", "/varl/log/-20121207-06", PerHour},
+	}
+	for _, test := range tests {
+		f := buildFilenameWithTimeExtension(now, test.vl, test.rotatingScheme)
+		if f != test.exp {
+			t.Fatal(f)
+		}
+	}
+}
+
+func TestMustFileBeRemoved(t *testing.T) {
+	lastFileTimePerDay, _ := time.Parse("2006 Jan 02", "2012 Dec 07")
+	lastFileTimePerHour, _ := time.Parse("2006 Jan 02 15", "2012 Dec 07 06")
+	tests := []struct {
+		vl           string
+		exp          bool
+		w            *Writer
+		lastFileTime time.Time
+		so           string
+	}{
+		{"/varl/log/teste.log", false, &Writer{filename: "/varl/log/teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "linux"},
+		{"/varl/log/teste", false, &Writer{filename: "/varl/log/teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "linux"},
+		{"C:\\Test.Legal\\Temp\\teste", false, &Writer{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "windows"},
+		{"/varl/log/", false, &Writer{filename: "/varl/log/teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "linux"},
+		{"C:\\Test.Legal\\Temp\\", false, &Writer{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "linux"},
+		{"/varl/log/teste.log", false, &Writer{filename: "/varl/log/teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
+		{"/varl/log/teste", false, &Writer{filename: "/varl/log/teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
+		{"C:\\Test.Legal\\Temp\\teste", false, &Writer{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "windows"},
+		{"/varl/log/", false, &Writer{filename: "/varl/log/teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
+		{"C:\\Test.Legal\\Temp\\", false, &Writer{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
+		{"/varl/log/teste-20121207.log", false, &Writer{filename: "/varl/log/teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "linux"},
+		{"/varl/log/teste-20121208.log", false, &Writer{filename: "/varl/log/teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "linux"},
+		{"C:\\Test.Legal\\Temp\\teste-20121207.log", false, &Writer{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "windows"},
+		{"C:\\Test.Legal\\Temp\\teste-20121208.log", false, &Writer{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "windows"},
+
+		{"/varl/log/teste.log.zip", false, &Writer{filename: "/varl/log/teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "linux"},
+		{"/varl/log/teste.zip", false, &Writer{filename: "/varl/log/teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "linux"},
+		{"C:\\Test.Legal\\Temp\\teste.zip", false, &Writer{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "windows"},
+		{"/varl/log/teste.log.zip", false, &Writer{filename: "/varl/log/teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
+		{"/varl/log/teste.zip", false, &Writer{filename: "/varl/log/teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
+		{"C:\\Test.Legal\\Temp\\teste.zip", false, &Writer{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "windows"},
+		{"/varl/log/", false, &Writer{filename: "/varl/log/teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
+		{"C:\\Test.Legal\\Temp\\", false, &Writer{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
+		{"/varl/log/teste-20121207.log.zip", false, &Writer{filename: "/varl/log/teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "linux"},
+		{"/varl/log/teste-20121208.log.zip", false, &Writer{filename: "/varl/log/teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "linux"},
+		{"C:\\Test.Legal\\Temp\\teste-20121207.log.zip", false, &Writer{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "windows"},
+		{"C:\\Test.Legal\\Temp\\teste-20121208.log.zip", false, &Writer{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "windows"},
+
+		{"/varl/log/teste-20121207-06.log", false, &Writer{filename: "/varl/log/teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
+		{"/varl/log/teste-20121207-07.log", false, &Writer{filename: "/varl/log/teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
+		{"C:\\Test.Legal\\Temp\\teste-20121207-06.log", false, &Writer{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
+		{"C:\\Test.Legal\\Temp\\teste-20121207-07.log", false, &Writer{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
+		{"/varl/log/teste-20121206.log", true, &Writer{filename: "/varl/log/teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "linux"},
+		{"C:\\Test.Legal\\Temp\\teste-20121206.log", true, &Writer{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "windows"},
+		{"/varl/log/teste-20121207-05.log", true, &Writer{filename: "/varl/log/teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
+		{"/varl/log/teste-20121206-07.log", true, &Writer{filename: "/varl/log/teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
+		{"C:\\Test.Legal\\Temp\\teste-20121206-05.log", true, &Writer{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
+		{"C:\\Test.Legal\\Temp\\teste-20121206-06.log", true, &Writer{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
+		{"C:\\Test.Legal\\Temp\\teste-20121206-07.log", true, &Writer{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
+
+		{"/varl/log/teste-20121207-06.log.zip", false, &Writer{filename: "/varl/log/teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
+		{"/varl/log/teste-20121207-07.log.zip", false, &Writer{filename: "/varl/log/teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
+		{"C:\\Test.Legal\\Temp\\teste-20121207-06.log.zip", false, &Writer{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
+		{"C:\\Test.Legal\\Temp\\teste-20121207-07.log.zip", false, &Writer{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
+		{"/varl/log/teste-20121206.log.zip", true, &Writer{filename: "/varl/log/teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "linux"},
+		{"C:\\Test.Legal\\Temp\\teste-20121206.log.zip", true, &Writer{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "windows"},
+		{"/varl/log/teste-20121207-05.log.zip", true, &Writer{filename: "/varl/log/teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
+		{"/varl/log/teste-20121206-07.log.zip", true, &Writer{filename: "/varl/log/teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
+		{"C:\\Test.Legal\\Temp\\teste-20121206-05.log.zip", true, &Writer{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
+		{"C:\\Test.Legal\\Temp\\teste-20121206-06.log.zip", true, &Writer{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
+		{"C:\\Test.Legal\\Temp\\teste-20121206-07.log.zip", true, &Writer{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
+
+		{"/varl/log/teste-20121207-06", false, &Writer{filename: "/varl/log/teste", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
+		{"/varl/log/teste-20121207-07", false, &Writer{filename: "/varl/log/teste", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
+		{"C:\\Test.Legal\\Temp\\teste-20121207-06", false, &Writer{filename: "C:\\Test.Legal\\Temp\\teste", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
+		{"C:\\Test.Legal\\Temp\\teste-20121207-07", false, &Writer{filename: "C:\\Test.Legal\\Temp\\teste", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
+		{"/varl/log/teste-20121206", true, &Writer{filename: "/varl/log/teste", rotatingScheme: PerDay}, lastFileTimePerDay, "linux"},
+		{"C:\\Test.Legal\\Temp\\teste-20121206", true, &Writer{filename: "C:\\Test.Legal\\Temp\\teste", rotatingScheme: PerDay}, lastFileTimePerDay, "windows"},
+		{"/varl/log/teste-20121207-05", true, &Writer{filename: "/varl/log/teste", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
+		{"/varl/log/teste-20121206-07", true, &Writer{filename: "/varl/log/teste", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
+		{"C:\\Test.Legal\\Temp\\teste-20121206-05", true, &Writer{filename: "C:\\Test.Legal\\Temp\\teste", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
+		{"C:\\Test.Legal\\Temp\\teste-20121206-06", true, &Writer{filename: "C:\\Test.Legal\\Temp\\teste", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
+		{"C:\\Test.Legal\\Temp\\teste-20121206-07", true, &Writer{filename: "C:\\Test.Legal\\Temp\\teste", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
+
+		{"/varl/log/teste-20121207-06.zip", false, &Writer{filename: "/varl/log/teste", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
+		{"/varl/log/teste-20121207-07.zip", false, &Writer{filename: "/varl/log/teste", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
+		{"C:\\Test.Legal\\Temp\\teste-20121207-06.zip", false, &Writer{filename: "C:\\Test.Legal\\Temp\\teste", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
+		{"C:\\Test.Legal\\Temp\\teste-20121207-07.zip", false, &Writer{filename: "C:\\Test.Legal\\Temp\\teste", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
+		{"/varl/log/teste-20121206.zip", true, &Writer{filename: "/varl/log/teste", rotatingScheme: PerDay}, lastFileTimePerDay, "linux"},
+		{"C:\\Test.Legal\\Temp\\teste-20121206.zip", true, &Writer{filename: "C:\\Test.Legal\\Temp\\teste", rotatingScheme: PerDay}, lastFileTimePerDay, "windows"},
+		{"/varl/log/teste-20121207-05.zip", true, &Writer{filename: "/varl/log/teste", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
+		{"/varl/log/teste-20121206-07.zip", true, &Writer{filename: "/varl/log/teste", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
+		{"C:\\Test.Legal\\Temp\\teste-20121206-05.zip", true, &Writer{filename: "C:\\Test.Legal\\Temp\\teste", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
+		{"C:\\Test.Legal\\Temp\\teste-20121206-06.zip", true, &Writer{filename: "C:\\Test.Legal\\Temp\\teste", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
+		{"C:\\Test.Legal\\Temp\\teste-20121206-07.zip", true, &Writer{filename: "C:\\Test.Legal\\Temp\\teste", rotatingScheme: PerHour}, lastFileTimePerHour, "windows"},
+
+		{"/varl/log/teste-20121207.log.gz", false, &Writer{filename: "/varl/log/teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "linux"},
+		{"/varl/log/teste-20121206.log.gz", true, &Writer{filename: "/varl/log/teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "linux"},
+		{"/varl/log/teste-20121207-05.log.gz", true, &Writer{filename: "/varl/log/teste.log", rotatingScheme: PerHour}, lastFileTimePerHour, "linux"},
+		{"/varl/log/teste-20121206.log.bz2", false, &Writer{filename: "/varl/log/teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "linux"},
+		{"/varl/log/teste-20121206.log.bz2", true, &Writer{filename: "/varl/log/teste.log", rotatingScheme: PerDay, compressor: &compressorTest{ext: ".bz2"}}, lastFileTimePerDay, "linux"},
+		{"C:\\Test.Legal\\Temp\\teste-20121206.log.gz", true, &Writer{filename: "C:\\Test.Legal\\Temp\\teste.log", rotatingScheme: PerDay}, lastFileTimePerDay, "windows"},
+	}
+	os := runtime.GOOS
+	for i, test := range tests {
+		if !strings.HasPrefix(test.so, os) {
+			continue
+		}
+		must := mustFileBeRemoved(test.lastFileTime, test.vl, test.w)
+		if must != test.exp {
+			t.Fatal(i, test)
+		}
+	}
+}
+
+func TestRemovePartialArchives(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := []struct {
+		name    string
+		removed bool
+	}{
+		{"teste-20121207.log", false},
+		{"teste-20121206.log.gz", false},
+		{"teste-20121206.log.gz.tmp", true},
+		{"teste-20121205.log.zip.tmp", true},
+		{"teste-notes.log.gz.tmp", false},
+		{"other-20121206.log.gz.tmp", false},
+	}
+	for _, f := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, f.name), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	removePartialArchives(&Writer{filename: filepath.Join(dir, "teste.log"), rotatingScheme: PerDay})
+	for _, f := range files {
+		_, err := os.Stat(filepath.Join(dir, f.name))
+		if f.removed != os.IsNotExist(err) {
+			t.Fatalf("File %s removed should be %v, but stat returned %v", f.name, f.removed, err)
+		}
+	}
+}
+
+func TestFinishPreviousFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "teste.log")
+	currentLogFilename := buildFilenameWithTimeExtension(time.Now(), filename, PerDay)
+	previousLogFilename := buildFilenameWithTimeExtension(time.Now().AddDate(0, 0, -1), filename, PerDay)
+	for _, f := range []string{currentLogFilename, previousLogFilename} {
+		if err := ioutil.WriteFile(f, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w := &Writer{
+		filename:           filename,
+		currentLogFilename: currentLogFilename,
+		rotatingScheme:     PerDay,
+		compressor:         &compressor.Zip{},
+		queue:              newWorkerQueue(1, 0),
+	}
+	w.queue.start()
+	finishPreviousFiles(w)
+	w.queue.stop()
+	for _, f := range []string{currentLogFilename, previousLogFilename + compressor.ZipExtension} {
+		if _, err := os.Stat(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(previousLogFilename); !os.IsNotExist(err) {
+		t.Fatalf("File %s should be compressed, but stat returned %v", previousLogFilename, err)
+	}
+}
+
+func TestBuildFilenameWithSequence(t *testing.T) {
+	now, _ := time.Parse("2006 Jan 02 15:04:05", "2012 Dec 07 06:15:30")
+	tests := []struct {
+		vl             string
+		sequence       int
+		exp            string
+		rotatingScheme TimeRotatingScheme
+	}{
+		{"/varl/log/teste.log", 0, "/varl/log/teste-20121207.log", PerDay},
+		{"/varl/log/teste.log", 1, "/varl/log/teste-20121207.1.log", PerDay},
+		{"/varl/log/teste", 12, "/varl/log/teste-20121207.12", PerDay},
+		{"/varl/log/teste.log", 2, "/varl/log/teste-20121207-06.2.log", PerHour},
+	}
+	for _, test := range tests {
+		f := buildFilenameWithSequence(now, test.vl, test.rotatingScheme, test.sequence)
+		if f != test.exp {
+			t.Fatal(f)
+		}
+	}
+}
+
+func TestMustFileWithSequenceBeRemoved(t *testing.T) {
+	lastFileTime, _ := time.Parse("2006 Jan 02", "2012 Dec 07")
+	tests := []struct {
+		vl  string
+		exp bool
+	}{
+		{"/varl/log/teste-20121206.1.log", true},
+		{"/varl/log/teste-20121206.10.log.gz", true},
+		{"/varl/log/teste-20121207.1.log", false},
+		{"/varl/log/teste-20121207.1.log.zip", false},
+		{"/varl/log/teste-20121206.a.log", false},
+	}
+	w := &Writer{filename: "/varl/log/teste.log", rotatingScheme: PerDay}
+	for _, test := range tests {
+		if must := mustFileBeRemoved(lastFileTime, test.vl, w); must != test.exp {
+			t.Fatal(test)
+		}
+	}
+}
+
+func TestBuildFilenameToResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	now, _ := time.Parse("2006 Jan 02", "2012 Dec 07")
+	w := &Writer{filename: filepath.Join(dir, "teste.log"), rotatingScheme: PerDay}
+	tests := []struct {
+		createFile string
+		exp        string
+	}{
+		{"", "teste-20121207.log"},
+		{"teste-20121207.log", "teste-20121207.log"},
+		{"teste-20121207.log.gz", "teste-20121207.1.log"},
+		{"teste-20121207.1.log", "teste-20121207.1.log"},
+		{"teste-20121207.2.log", "teste-20121207.2.log"},
+		{"teste-20121207.2.log.zip", "teste-20121207.3.log"},
+	}
+	for _, test := range tests {
+		if test.createFile != "" {
+			if err := ioutil.WriteFile(filepath.Join(dir, test.createFile), []byte("x"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		f := buildFilenameToResume(now, w)
+		if f != filepath.Join(dir, test.exp) {
+			t.Fatalf("Expected %s, but received %s", test.exp, f)
+		}
+	}
+}
+
+func TestRotate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "teste.log")
+	c, err := compressor.NewGzip(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := New(filename, Options{RotatingScheme: PerDay, AmountOfFilesToRetain: 1, Compressor: c})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := w.Write([]byte("teste\n")); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Rotate(); err != ErrClosed {
+		t.Fatalf("Expected %v, but received %v", ErrClosed, err)
+	}
+	if _, err := w.Write([]byte("teste\n")); err != ErrClosed {
+		t.Fatalf("Expected %v, but received %v", ErrClosed, err)
+	}
+	if err := w.Close(); err != ErrClosed {
+		t.Fatalf("Expected %v, but received %v", ErrClosed, err)
+	}
+	now := time.Now()
+	for _, f := range []string{
+		buildFilenameWithSequence(now, filename, PerDay, 0) + compressor.GzipExtension,
+		buildFilenameWithSequence(now, filename, PerDay, 1) + compressor.GzipExtension,
+		buildFilenameWithSequence(now, filename, PerDay, 2),
+	} {
+		if _, err := os.Stat(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRotateWithPermissions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	logDir := filepath.Join(dir, "logs")
+	filename := filepath.Join(logDir, "teste.log")
+	w, err := New(filename, Options{RotatingScheme: PerDay, Compressor: NewZipCompressor(), FileMode: 0600, DirMode: 0700})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for f, mode := range map[string]os.FileMode{
+		logDir: os.ModeDir | 0700,
+		buildFilenameWithTimeExtension(now, filename, PerDay) + compressor.ZipExtension: 0600,
+		buildFilenameWithSequence(now, filename, PerDay, 1):                             0600,
+	} {
+		info, err := os.Stat(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode() != mode {
+			t.Fatalf("Expected mode %v of %s, but received %v", mode, f, info.Mode())
+		}
+	}
+}
+
+func TestLastFileTimeToRetain(t *testing.T) {
+	lastFileTimePerDay, _ := time.Parse("2006 Jan 02", "2012 Dec 07")
+	lastFileTimePerHour, _ := time.Parse("2006 Jan 02 15", "2012 Dec 07 06")
+	tests := []struct {
+		vl  time.Time
+		exp time.Time
+		w   *Writer
+	}{
+		{lastFileTimePerDay, time.Date(2012, 12, 7, 0, 0, 0, 0, time.UTC), &Writer{rotatingScheme: PerDay, amountOfFilesToRetain: 0}},
+		{lastFileTimePerDay, time.Date(2012, 12, 6, 0, 0, 0, 0, time.UTC), &Writer{rotatingScheme: PerDay, amountOfFilesToRetain: 1}},
+		{lastFileTimePerDay, time.Date(2012, 11, 27, 0, 0, 0, 0, time.UTC), &Writer{rotatingScheme: PerDay, amountOfFilesToRetain: 10}},
+		{lastFileTimePerHour, time.Date(2012, 12, 7, 6, 0, 0, 0, time.UTC), &Writer{rotatingScheme: PerHour, amountOfFilesToRetain: 0}},
+		{lastFileTimePerHour, time.Date(2012, 12, 7, 5, 0, 0, 0, time.UTC), &Writer{rotatingScheme: PerHour, amountOfFilesToRetain: 1}},
+		{lastFileTimePerHour, time.Date(2012, 12, 7, 0, 0, 0, 0, time.UTC), &Writer{rotatingScheme: PerHour, amountOfFilesToRetain: 6}},
+		{lastFileTimePerHour, time.Date(2012, 12, 6, 22, 0, 0, 0, time.UTC), &Writer{rotatingScheme: PerHour, amountOfFilesToRetain: 8}},
+	}
+	for _, test := range tests {
+		last := lastFileTimeToRetain(test.vl, test.w)
+		if !last.Equal(test.exp) {
+			t.Fatal(last)
+		}
+	}
+}
+
+func TestGetFilenameGlobWithoutExt(t *testing.T) {
+	tests := []struct {
+		vl       string
+		expected string
+	}{
+		{"/var/log/teste.log", "/var/log/teste*"},
+		{"/var/log/teste-.log", "/var/log/teste-*"},
+		{"/var/log/teste-23.log", "/var/log/teste-23*"},
+		{"/var/log/teste.log.zip", "/var/log/teste*"},
+		{"teste.log", "teste*"},
+		{"/var/log/teste", "/var/log/teste*"},
+		{"teste", "teste*"},
+		{"teste.log.zip", "teste*"},
+	}
+	for _, test := range tests {
+		vl := getFilenameGlobWithoutExt(test.vl)
+		if vl != test.expected {
+			t.Fatalf("Expected %s, but received %s", test.expected, vl)
+		}
+	}
+}
+
+func TestGetFilenameExt(t *testing.T) {
+	tests := []struct {
+		vl       string
+		expected string
+	}{
+		{"/var/log/teste.log", ".log"},
+		{"/var/log/teste-.log", ".log"},
+		{"/var/log/teste-23.log", ".log"},
+		{"teste.log", ".log"},
+		{"/var/log/teste", ""},
+		{"teste", ""},
+		{"teste.log.zip", ".log.zip"},
+		{"/var/log/teste.log.zip", ".log.zip"},
+		{"teste.log.gz", ".log.gz"},
+		{"/var/log/teste.log.gz", ".log.gz"},
+	}
+	for _, test := range tests {
+		vl := getFilenameExt(test.vl, true)
+		if vl != test.expected {
+			t.Fatalf("Expected %s, but received %s", test.expected, vl)
+		}
+	}
+}
+
+func TestGetFilenameWithoutExt(t *testing.T) {
+	tests := []struct {
+		vl       string
+		expected string
+	}{
+		{"/var/log/teste.log", "/var/log/teste"},
+		{"/var/log/teste-.log", "/var/log/teste-"},
+		{"/var/log/teste-23.log", "/var/log/teste-23"},
+		{"/var/log/teste.log.zip", "/var/log/teste"},
+		{"teste.log", "teste"},
+		{"/var/log/teste", "/var/log/teste"},
+		{"teste", "teste"},
+		{"teste.log.zip", "teste"},
+	}
+	for _, test := range tests {
+		vl := getFilenameWithoutExt(test.vl)
+		if vl != test.expected {
+			t.Fatalf("Expected %s, but received %s", test.expected, vl)
+		}
+	}
+}
+
+type compressorTest struct {
+	ext string
+}
+
+func (c *compressorTest) Extension() string {
+	return c.ext
+}
+
+func (c *compressorTest) NewWriter(w io.Writer, name string) (io.WriteCloser, error) {
+	return nil, nil
+}
+
+func (c *compressorTest) NewReader(r io.ReaderAt, size int64) (io.ReadCloser, error) {
+	return nil, nil
+}
+
+func TestRetentionWaitsTheCompressions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "teste.log")
+	now := time.Now()
+	c := slowCompressor{Compressor: NewZipCompressor(), slow: map[string]bool{}}
+	var old []string
+	for _, days := range []int{-3, -2} {
+		f := buildFilenameWithTimeExtension(now.AddDate(0, 0, days), filename, PerDay)
+		if err := ioutil.WriteFile(f, []byte("old\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		c.slow[filepath.Base(f)] = true
+		old = append(old, f)
+	}
+	var mux sync.Mutex
+	var reported []*Error
+	w, err := New(filename, Options{
+		RotatingScheme:         PerDay,
+		AmountOfFilesToRetain:  1,
+		Compressor:             c,
+		CompressionConcurrency: 4,
+		ErrorHandler: func(err *Error) {
+			mux.Lock()
+			reported = append(reported, err)
+			mux.Unlock()
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	// the retention after the rotation runs while the files of the previous days are still being compressed
+	if err := w.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	w.WaitIdle()
+	mux.Lock()
+	defer mux.Unlock()
+	if len(reported) > 0 {
+		t.Fatalf("Expected no errors, but received %v", reported)
+	}
+	leftovers, err := filepath.Glob(filepath.Join(dir, "teste-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range leftovers {
+		for _, o := range old {
+			if strings.HasPrefix(f, o) {
+				t.Fatalf("Expected the files of the previous days removed, but found %v", leftovers)
+			}
+		}
+	}
+}
+
+// slowCompressor takes a while to compress the files named in slow
+type slowCompressor struct {
+	Compressor
+	slow map[string]bool
+}
+
+func (c slowCompressor) NewWriter(w io.Writer, name string) (io.WriteCloser, error) {
+	if c.slow[name] {
+		time.Sleep(100 * time.Millisecond)
+	}
+	return c.Compressor.NewWriter(w, name)
+}
@@ -0,0 +1,84 @@
+package shipper
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Destination receives the content of the shipped files. Ship is called again for the same file when it fails
+type Destination interface {
+	Ship(filename string, size int64, content io.Reader) error
+}
+
+// LocalDir copies the files into Dir, renaming them into place after synced
+type LocalDir struct {
+	Dir string
+}
+
+func (d *LocalDir) Ship(filename string, size int64, content io.Reader) error {
+	if err := os.MkdirAll(d.Dir, 0755); err != nil {
+		return err
+	}
+	destination := filepath.Join(d.Dir, filepath.Base(filename))
+	tempDestination := destination + ".tmp"
+	err := writeFile(tempDestination, content)
+	if err != nil {
+		os.Remove(tempDestination)
+		return err
+	}
+	return os.Rename(tempDestination, destination)
+}
+
+func writeFile(filename string, content io.Reader) error {
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err = io.Copy(f, content); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// HTTPPut sends the files with PUT requests to URL/<file base name>
+type HTTPPut struct {
+	URL    string
+	Header http.Header
+	// Client sends the requests. Nil uses http.DefaultClient
+	Client *http.Client
+}
+
+func (h *HTTPPut) Ship(filename string, size int64, content io.Reader) error {
+	req, err := http.NewRequest(http.MethodPut, strings.TrimSuffix(h.URL, "/")+"/"+url.PathEscape(filepath.Base(filename)), content)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	for k, v := range h.Header {
+		req.Header[k] = v
+	}
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("shipping to %s failed with status %s", req.URL, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,360 @@
+// Package shipper ships the files finished by a rotatingfile.Writer to a destination, keeping them from the
+// retention until they are shipped
+package shipper
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Murilovisque/logs/v3/internal/fileutil"
+	"github.com/Murilovisque/logs/v3/rotatingfile"
+)
+
+const (
+	recordPending = "pending"
+	recordShipped = "shipped"
+	// tempExtension is appended to the record file while it is compacted
+	tempExtension = ".tmp"
+)
+
+var (
+	ErrInvalidMaxRetries = errors.New("max retries is less than zero")
+	ErrInvalidRecord     = errors.New("invalid shipping record line")
+)
+
+// Options configures a Shipper
+type Options struct {
+	// RecordFile persists the files pending to be shipped. It is created if it does not exist and compacted as the
+	// files are shipped
+	RecordFile string
+	// MaxRetries is the amount of retries after the first attempt fails
+	MaxRetries int
+	// InitialBackoff is the wait before the first retry, doubled on each retry up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Logger receives the shipping failures. Nil discards them
+	Logger rotatingfile.Logger
+}
+
+// Shipper sends the finished files to a Destination. Its Hook is a rotatingfile.PostRotateHook and the Shipper
+// itself is a rotatingfile.RetentionGuard retaining the files pending. The files are shipped by a goroutine of the
+// Shipper, so the retries never hold the workers of the writer
+type Shipper struct {
+	destination    Destination
+	recordFile     string
+	record         *os.File
+	mux            sync.Mutex
+	pending        map[string]bool
+	queue          []string
+	queued         chan struct{}
+	inFlight       sync.WaitGroup
+	stop           chan struct{}
+	done           chan struct{}
+	closeOnce      sync.Once
+	maxRetries     int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	logger         rotatingfile.Logger
+	sleep          func(time.Duration)
+}
+
+func New(destination Destination, opts Options) (*Shipper, error) {
+	if opts.MaxRetries < 0 {
+		return nil, ErrInvalidMaxRetries
+	}
+	s := Shipper{
+		destination:    destination,
+		recordFile:     opts.RecordFile,
+		pending:        make(map[string]bool),
+		queued:         make(chan struct{}, 1),
+		stop:           make(chan struct{}),
+		done:           make(chan struct{}),
+		maxRetries:     opts.MaxRetries,
+		initialBackoff: opts.InitialBackoff,
+		maxBackoff:     opts.MaxBackoff,
+		logger:         opts.Logger,
+	}
+	s.sleep = s.wait
+	if s.logger == nil {
+		s.logger = nopLogger{}
+	}
+	if err := loadRecord(opts.RecordFile, &s); err != nil {
+		return nil, err
+	}
+	// the record is compacted on open too, dropping the files shipped before the restart
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+	go shipping(&s)
+	return &s, nil
+}
+
+// Hook records the rotated file as pending and ships it in background, it must be added to
+// rotatingfile.Options.PostRotateHooks. After Close, the file is only recorded as pending
+func (s *Shipper) Hook(f rotatingfile.RotatedFile) {
+	if err := s.recordPending(f.Path); err != nil {
+		s.logger.Errorf("It was not possible record the file %s as pending - Error: %s", f.Path, err)
+	}
+	s.mux.Lock()
+	if s.stopped() {
+		s.mux.Unlock()
+		return
+	}
+	s.inFlight.Add(1)
+	s.queue = append(s.queue, f.Path)
+	s.mux.Unlock()
+	select {
+	case s.queued <- struct{}{}:
+	default:
+	}
+}
+
+// WaitIdle blocks until the files passed to Hook so far were shipped or failed to be
+func (s *Shipper) WaitIdle() {
+	s.inFlight.Wait()
+}
+
+// Ship sends the file to the destination, retrying with backoff, and removes it from the pending files. The retries
+// stop when the Shipper is closed, the file staying pending
+func (s *Shipper) Ship(filename string) error {
+	if err := s.recordPending(filename); err != nil {
+		return err
+	}
+	backoff := s.initialBackoff
+	err := shipFile(s.destination, filename)
+	for retry := 0; err != nil && retry < s.maxRetries && !s.stopped(); retry++ {
+		s.logger.Debugf("Shipping of %s failed, retrying in %v - Error: %s", filename, backoff, err)
+		s.sleep(backoff)
+		if s.stopped() {
+			break
+		}
+		backoff *= 2
+		if s.maxBackoff > 0 && backoff > s.maxBackoff {
+			backoff = s.maxBackoff
+		}
+		err = shipFile(s.destination, filename)
+	}
+	if err != nil {
+		return err
+	}
+	return s.drop(filename)
+}
+
+// RetryPending ships again the files whose shipping failed, returning the last error. The files removed in the
+// meantime are dropped from the pending files
+func (s *Shipper) RetryPending() error {
+	s.mux.Lock()
+	filenames := make([]string, 0, len(s.pending))
+	for filename := range s.pending {
+		filenames = append(filenames, filename)
+	}
+	s.mux.Unlock()
+	sort.Strings(filenames)
+	var lastErr error
+	for _, filename := range filenames {
+		var err error
+		if _, statErr := os.Stat(filename); os.IsNotExist(statErr) {
+			err = s.drop(filename)
+		} else {
+			err = s.Ship(filename)
+		}
+		if err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// Retain keeps the files pending, the files never passed to the Shipper are not retained
+func (s *Shipper) Retain(filename string) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.pending[filename]
+}
+
+// Close stops the shipping, waiting the file being shipped. The files not shipped yet stay pending in the record,
+// to be shipped by RetryPending after the restart
+func (s *Shipper) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+	})
+	<-s.done
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.record.Close()
+}
+
+// shipping ships the files passed to Hook, in the order they were finished
+func shipping(s *Shipper) {
+	defer close(s.done)
+	for {
+		select {
+		case <-s.queued:
+			for filename, ok := s.dequeue(); ok; filename, ok = s.dequeue() {
+				if err := s.Ship(filename); err != nil {
+					s.logger.Errorf("It was not possible ship the file %s - Error: %s", filename, err)
+				}
+				s.inFlight.Done()
+			}
+		case <-s.stop:
+			s.mux.Lock()
+			for range s.queue {
+				s.inFlight.Done()
+			}
+			s.queue = nil
+			s.mux.Unlock()
+			return
+		}
+	}
+}
+
+func (s *Shipper) dequeue() (string, bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if len(s.queue) == 0 || s.stopped() {
+		return "", false
+	}
+	filename := s.queue[0]
+	s.queue = s.queue[1:]
+	return filename, true
+}
+
+func (s *Shipper) stopped() bool {
+	select {
+	case <-s.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// wait sleeps the backoff, waking up when the Shipper is closed
+func (s *Shipper) wait(d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-s.stop:
+	}
+}
+
+// recordPending appends the file to the record, unless it is already pending
+func (s *Shipper) recordPending(filename string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.pending[filename] {
+		return nil
+	}
+	if _, err := s.record.WriteString(recordPending + "\t" + filename + "\n"); err != nil {
+		return err
+	}
+	if err := s.record.Sync(); err != nil {
+		return err
+	}
+	s.pending[filename] = true
+	return nil
+}
+
+// drop removes the file from the pending files, compacting the record
+func (s *Shipper) drop(filename string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if !s.pending[filename] {
+		return nil
+	}
+	delete(s.pending, filename)
+	return s.compact()
+}
+
+// compact rewrites the record with only the pending files, replacing it atomically, then reopens it for the appends.
+// s.mux must be held
+func (s *Shipper) compact() error {
+	filenames := make([]string, 0, len(s.pending))
+	for filename := range s.pending {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+	tempFilename := s.recordFile + tempExtension
+	temp, err := os.OpenFile(tempFilename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(temp)
+	for _, filename := range filenames {
+		w.WriteString(recordPending + "\t" + filename + "\n")
+	}
+	err = w.Flush()
+	if err == nil {
+		err = temp.Sync()
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tempFilename, s.recordFile)
+	}
+	if err != nil {
+		os.Remove(tempFilename)
+		return err
+	}
+	fileutil.SyncDir(filepath.Dir(s.recordFile))
+	if s.record != nil {
+		s.record.Close()
+	}
+	s.record, err = os.OpenFile(s.recordFile, os.O_APPEND|os.O_WRONLY, 0644)
+	return err
+}
+
+// applyRecord applies a line of the record, the shipped lines are written only by the older versions
+func applyRecord(status, filename string, s *Shipper) {
+	if status == recordShipped {
+		delete(s.pending, filename)
+	} else {
+		s.pending[filename] = true
+	}
+}
+
+func loadRecord(recordFile string, s *Shipper) error {
+	f, err := os.Open(recordFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), "\t", 2)
+		if len(fields) != 2 || (fields[0] != recordPending && fields[0] != recordShipped) {
+			return ErrInvalidRecord
+		}
+		applyRecord(fields[0], fields[1], s)
+	}
+	return scanner.Err()
+}
+
+func shipFile(destination Destination, filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return destination.Ship(filename, info.Size(), f)
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debugf(message string, v ...interface{}) {}
+func (nopLogger) Infof(message string, v ...interface{})  {}
+func (nopLogger) Errorf(message string, v ...interface{}) {}
@@ -0,0 +1,217 @@
+package shipper
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Murilovisque/logs/v3/rotatingfile"
+)
+
+func TestShipWithHTTPPutRetryingWithBackoff(t *testing.T) {
+	dir, filename := setup(t)
+	defer os.RemoveAll(dir)
+	server := newServerTest(2)
+	defer server.Close()
+	s, err := New(&HTTPPut{URL: server.URL + "/logs/"}, Options{RecordFile: filepath.Join(dir, "shipped"), MaxRetries: 3, InitialBackoff: time.Second, MaxBackoff: 1500 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	backoffs := []time.Duration{}
+	s.sleep = func(d time.Duration) {
+		backoffs = append(backoffs, d)
+	}
+	if s.Retain(filename) {
+		t.Fatal("File never passed to the shipper should not be retained")
+	}
+	s.Hook(rotatingfile.RotatedFile{Path: filename})
+	s.WaitIdle()
+	if s.Retain(filename) {
+		t.Fatal("File shipped should not be retained")
+	}
+	if len(backoffs) != 2 || backoffs[0] != time.Second || backoffs[1] != 1500*time.Millisecond {
+		t.Fatalf("Unexpected backoffs %v", backoffs)
+	}
+	if server.bodies["/logs/teste-20121207.log"] != "conteúdo" {
+		t.Fatalf("Unexpected content shipped %v", server.bodies)
+	}
+	s.Close()
+
+	s, err = New(&HTTPPut{URL: server.URL}, Options{RecordFile: filepath.Join(dir, "shipped")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if s.Retain(filename) {
+		t.Fatal("File shipped before the restart should not be retained")
+	}
+}
+
+func TestRetryPending(t *testing.T) {
+	dir, filename := setup(t)
+	defer os.RemoveAll(dir)
+	server := newServerTest(1)
+	defer server.Close()
+	s, err := New(&HTTPPut{URL: server.URL}, Options{RecordFile: filepath.Join(dir, "shipped")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Ship(filename); err == nil {
+		t.Fatal("Shipping should fail")
+	}
+	s.Close()
+
+	s, err = New(&HTTPPut{URL: server.URL}, Options{RecordFile: filepath.Join(dir, "shipped")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if !s.Retain(filename) {
+		t.Fatal("File not shipped should be retained")
+	}
+	if err := s.RetryPending(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Retain(filename) {
+		t.Fatal("File shipped should not be retained")
+	}
+}
+
+func TestHookShipsInBackground(t *testing.T) {
+	dir, filename := setup(t)
+	defer os.RemoveAll(dir)
+	destination := &blockingDestination{shipping: make(chan struct{}), release: make(chan struct{})}
+	recordFile := filepath.Join(dir, "shipped")
+	s, err := New(destination, Options{RecordFile: recordFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.Hook(rotatingfile.RotatedFile{Path: filename})
+	<-destination.shipping
+	if !s.Retain(filename) {
+		t.Fatal("File being shipped should be retained")
+	}
+	assertRecord(t, recordFile, "pending\t"+filename+"\n")
+	close(destination.release)
+	s.WaitIdle()
+	if s.Retain(filename) {
+		t.Fatal("File shipped should not be retained")
+	}
+	assertRecord(t, recordFile, "")
+}
+
+func TestCloseKeepsTheFilesPending(t *testing.T) {
+	dir, filename := setup(t)
+	defer os.RemoveAll(dir)
+	server := newServerTest(1)
+	defer server.Close()
+	recordFile := filepath.Join(dir, "shipped")
+	s, err := New(&HTTPPut{URL: server.URL}, Options{RecordFile: recordFile, MaxRetries: 1, InitialBackoff: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Hook(rotatingfile.RotatedFile{Path: filename})
+	// the retry waits the backoff until the Shipper is closed
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	s.WaitIdle()
+	assertRecord(t, recordFile, "pending\t"+filename+"\n")
+}
+
+func TestShipWithLocalDir(t *testing.T) {
+	dir, filename := setup(t)
+	defer os.RemoveAll(dir)
+	destinationDir := filepath.Join(dir, "shipped-files")
+	s, err := New(&LocalDir{Dir: destinationDir}, Options{RecordFile: filepath.Join(dir, "shipped")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.Ship(filename); err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(filepath.Join(destinationDir, filepath.Base(filename)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "conteúdo" {
+		t.Fatalf("Expected conteúdo, but received %s", content)
+	}
+}
+
+func TestNewWithInvalidMaxRetries(t *testing.T) {
+	if _, err := New(&LocalDir{}, Options{MaxRetries: -1}); err != ErrInvalidMaxRetries {
+		t.Fatalf("Expected %v, but received %v", ErrInvalidMaxRetries, err)
+	}
+}
+
+func assertRecord(t *testing.T, recordFile, expected string) {
+	t.Helper()
+	content, err := ioutil.ReadFile(recordFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != expected {
+		t.Fatalf("Expected the record '%s', but found '%s'", expected, content)
+	}
+}
+
+func setup(t *testing.T) (string, string) {
+	dir, err := ioutil.TempDir("", "teste-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(dir, "teste-20121207.log")
+	if err := ioutil.WriteFile(filename, []byte("conteúdo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir, filename
+}
+
+// serverTest fails the first requests
+type serverTest struct {
+	*httptest.Server
+	mux      sync.Mutex
+	failures int
+	bodies   map[string]string
+}
+
+func newServerTest(failures int) *serverTest {
+	s := &serverTest{failures: failures, bodies: make(map[string]string)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mux.Lock()
+		defer s.mux.Unlock()
+		if r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if s.failures > 0 {
+			s.failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		s.bodies[r.URL.Path] = string(body)
+	}))
+	return s
+}
+
+// blockingDestination blocks the shipping until release is closed
+type blockingDestination struct {
+	shipping chan struct{}
+	release  chan struct{}
+}
+
+func (d *blockingDestination) Ship(filename string, size int64, content io.Reader) error {
+	close(d.shipping)
+	<-d.release
+	return nil
+}